@@ -15,6 +15,6 @@ var (
 func main() {
 	cli.SetVersion(version, commit)
 	if err := cli.Execute(); err != nil {
-		os.Exit(1)
+		os.Exit(cli.ExitCode(err))
 	}
 }
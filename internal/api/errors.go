@@ -0,0 +1,202 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jaknapp/applink/internal/config"
+)
+
+// Error is an error reported by a service's API. Some services (like
+// Slack) report errors in HTTP 200 responses, so StatusCode may be 200.
+type Error struct {
+	Service    string // Service ID
+	StatusCode int    // HTTP status code
+	Code       string // Service-specific error code (e.g. "invalid_auth")
+	Message    string // Human-readable message, if the service gave one
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%s API error: %s", e.Service, e.Code)
+	if e.Message != "" && e.Message != e.Code {
+		msg += " (" + e.Message + ")"
+	}
+	return msg
+}
+
+// authErrorCodes are error codes meaning the token is invalid, expired
+// or revoked
+var authErrorCodes = map[string]bool{
+	// Slack
+	"invalid_auth":     true,
+	"not_authed":       true,
+	"token_expired":    true,
+	"token_revoked":    true,
+	"account_inactive": true,
+	// Notion
+	"unauthorized": true,
+	// GraphQL (Linear and others)
+	"AUTHENTICATION_ERROR": true,
+	"UNAUTHENTICATED":      true,
+}
+
+// IsAuthError reports whether the error means the stored token no longer
+// works and the user needs to log in again
+func (e *Error) IsAuthError() bool {
+	return e.StatusCode == http.StatusUnauthorized || authErrorCodes[e.Code]
+}
+
+// IsScopeError reports whether the token lacks a permission the call needs
+func (e *Error) IsScopeError() bool {
+	switch e.Code {
+	case "missing_scope", "restricted_resource", "FORBIDDEN":
+		return true
+	}
+	return e.StatusCode == http.StatusForbidden
+}
+
+// CheckResponse looks for a service-specific error in an API response.
+// It returns nil if the response is a success.
+func CheckResponse(service *config.Service, statusCode int, body []byte) *Error {
+	var payload map[string]interface{}
+	isJSON := json.Unmarshal(body, &payload) == nil
+
+	if isJSON {
+		var apiErr *Error
+		switch service.ID {
+		case "slack":
+			apiErr = slackError(payload)
+		case "notion":
+			apiErr = notionError(payload)
+		}
+		if apiErr == nil && service.GraphQLURL != "" {
+			apiErr = graphQLPayloadError(payload)
+		}
+		if apiErr != nil {
+			apiErr.Service = service.ID
+			apiErr.StatusCode = statusCode
+			return apiErr
+		}
+	}
+
+	if statusCode < 400 {
+		return nil
+	}
+
+	// Generic HTTP error, with whatever message the body carries
+	apiErr := &Error{
+		Service:    service.ID,
+		StatusCode: statusCode,
+		Code:       strings.ToLower(strings.ReplaceAll(http.StatusText(statusCode), " ", "_")),
+	}
+	if apiErr.Code == "" {
+		apiErr.Code = fmt.Sprintf("http_%d", statusCode)
+	}
+	if isJSON {
+		apiErr.Message = firstString(payload, "message", "error", "error_description")
+	}
+	return apiErr
+}
+
+// GraphQLErrors converts the errors array of a GraphQL response into an
+// Error. It returns nil if errs is empty.
+func GraphQLErrors(service *config.Service, statusCode int, errs []GraphQLError) *Error {
+	apiErr := graphQLError(errs)
+	if apiErr == nil {
+		return nil
+	}
+	apiErr.Service = service.ID
+	apiErr.StatusCode = statusCode
+	return apiErr
+}
+
+func graphQLError(errs []GraphQLError) *Error {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	messages := make([]string, len(errs))
+	for i, e := range errs {
+		messages[i] = e.String()
+	}
+
+	code := "GRAPHQL_ERROR"
+	if c, ok := errs[0].Extensions["code"].(string); ok && c != "" {
+		code = c
+	}
+	// Linear reports the code as extensions.type
+	if t, ok := errs[0].Extensions["type"].(string); ok && code == "GRAPHQL_ERROR" && t != "" {
+		code = strings.ToUpper(strings.ReplaceAll(t, " ", "_"))
+	}
+
+	return &Error{
+		Code:    code,
+		Message: strings.Join(messages, "; "),
+	}
+}
+
+// slackError handles Slack's {"ok": false, "error": "..."} responses
+func slackError(payload map[string]interface{}) *Error {
+	ok, exists := payload["ok"].(bool)
+	if !exists || ok {
+		return nil
+	}
+
+	code, _ := payload["error"].(string)
+	if code == "" {
+		code = "unknown_error"
+	}
+
+	apiErr := &Error{Code: code}
+	if needed, _ := payload["needed"].(string); needed != "" {
+		apiErr.Message = "needs scope " + needed
+		if provided, _ := payload["provided"].(string); provided != "" {
+			apiErr.Message += ", token has " + provided
+		}
+	}
+	return apiErr
+}
+
+// notionError handles Notion's {"object": "error", "code": "..."} responses
+func notionError(payload map[string]interface{}) *Error {
+	if object, _ := payload["object"].(string); object != "error" {
+		return nil
+	}
+
+	code, _ := payload["code"].(string)
+	if code == "" {
+		code = "unknown_error"
+	}
+	message, _ := payload["message"].(string)
+	return &Error{Code: code, Message: message}
+}
+
+// graphQLPayloadError handles a non-empty "errors" array in a GraphQL response
+func graphQLPayloadError(payload map[string]interface{}) *Error {
+	rawErrors, ok := payload["errors"].([]interface{})
+	if !ok || len(rawErrors) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(rawErrors)
+	if err != nil {
+		return nil
+	}
+	var errs []GraphQLError
+	if err := json.Unmarshal(data, &errs); err != nil {
+		return nil
+	}
+
+	return graphQLError(errs)
+}
+
+func firstString(payload map[string]interface{}, keys ...string) string {
+	for _, k := range keys {
+		if s, ok := payload[k].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/jaknapp/applink/internal/config"
+	"github.com/jaknapp/applink/internal/storage"
+)
+
+// GraphQLRequest is the body of a GraphQL POST request
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+}
+
+// GraphQLResponse is the body of a GraphQL response
+type GraphQLResponse struct {
+	Data   json.RawMessage `json:"data,omitempty"`
+	Errors []GraphQLError  `json:"errors,omitempty"`
+
+	StatusCode int `json:"-"` // HTTP status of the response
+}
+
+// GraphQLError is a single entry of a GraphQL response's errors array
+type GraphQLError struct {
+	Message    string                 `json:"message"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+func (e GraphQLError) String() string {
+	if len(e.Path) == 0 {
+		return e.Message
+	}
+	parts := make([]string, len(e.Path))
+	for i, p := range e.Path {
+		parts[i] = fmt.Sprint(p)
+	}
+	return fmt.Sprintf("%s (at %s)", e.Message, strings.Join(parts, "."))
+}
+
+// DoGraphQL posts a GraphQL request to the service's GraphQL endpoint.
+// GraphQL errors are returned in the response, not as an error.
+func DoGraphQL(client *http.Client, service *config.Service, token *storage.Token, gqlReq *GraphQLRequest) (*GraphQLResponse, error) {
+	return DoGraphQLContext(context.Background(), client, service, token, gqlReq)
+}
+
+// DoGraphQLContext is DoGraphQL with a context
+func DoGraphQLContext(ctx context.Context, client *http.Client, service *config.Service, token *storage.Token, gqlReq *GraphQLRequest) (*GraphQLResponse, error) {
+	if service.GraphQLURL == "" {
+		return nil, fmt.Errorf("%s does not have a GraphQL API", service.Name)
+	}
+
+	payload, err := json.Marshal(gqlReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode GraphQL request: %w", err)
+	}
+
+	req, err := NewRequest(service, token, http.MethodPost, service.GraphQLURL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var gqlResp GraphQLResponse
+	if err := json.Unmarshal(body, &gqlResp); err != nil {
+		if resp.StatusCode >= 400 {
+			return nil, CheckResponse(service, resp.StatusCode, body)
+		}
+		return nil, fmt.Errorf("failed to parse GraphQL response: %w", err)
+	}
+
+	// Some servers answer failed requests with an empty body of the right shape
+	if resp.StatusCode >= 400 && len(gqlResp.Errors) == 0 {
+		return nil, CheckResponse(service, resp.StatusCode, body)
+	}
+	gqlResp.StatusCode = resp.StatusCode
+
+	return &gqlResp, nil
+}
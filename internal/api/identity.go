@@ -0,0 +1,158 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/jaknapp/applink/internal/config"
+	"github.com/jaknapp/applink/internal/storage"
+)
+
+// Identity describes the account a token belongs to
+type Identity struct {
+	UserID      string
+	User        string // Name or email
+	WorkspaceID string
+	Workspace   string
+}
+
+// Apply copies the identity onto a token, keeping existing values for
+// fields the service didn't report
+func (id *Identity) Apply(token *storage.Token) {
+	if id.UserID != "" {
+		token.UserID = id.UserID
+	}
+	if id.User != "" {
+		token.User = id.User
+	}
+	if id.WorkspaceID != "" {
+		token.WorkspaceID = id.WorkspaceID
+	}
+	if id.Workspace != "" {
+		token.Workspace = id.Workspace
+	}
+}
+
+// LookupIdentity asks the service who the token belongs to, using the
+// identity endpoint declared on the service. API errors are returned as
+// *Error, so callers can tell revoked tokens from network failures.
+func LookupIdentity(ctx context.Context, client *http.Client, service *config.Service, token *storage.Token) (*Identity, error) {
+	if service.IdentityQuery != "" {
+		return lookupGraphQLIdentity(ctx, client, service, token)
+	}
+	if service.IdentityPath == "" {
+		return nil, fmt.Errorf("%s has no identity endpoint", service.Name)
+	}
+
+	req, err := NewRequest(service, token, http.MethodGet, service.APIURL+service.IdentityPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if apiErr := CheckResponse(service, resp.StatusCode, body); apiErr != nil {
+		return nil, apiErr
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("failed to parse identity response: %w", err)
+	}
+
+	return parseIdentity(service, payload), nil
+}
+
+// parseIdentity extracts the identity from a service's identity response
+func parseIdentity(service *config.Service, payload map[string]interface{}) *Identity {
+	id := &Identity{}
+
+	switch service.ID {
+	case "slack":
+		// auth.test
+		id.UserID, _ = payload["user_id"].(string)
+		id.User, _ = payload["user"].(string)
+		id.WorkspaceID, _ = payload["team_id"].(string)
+		id.Workspace, _ = payload["team"].(string)
+	case "notion":
+		// /v1/users/me returns the integration's bot user
+		id.UserID, _ = payload["id"].(string)
+		id.User, _ = payload["name"].(string)
+		if bot, ok := payload["bot"].(map[string]interface{}); ok {
+			id.Workspace, _ = bot["workspace_name"].(string)
+			if owner, ok := bot["owner"].(map[string]interface{}); ok {
+				if user, ok := owner["user"].(map[string]interface{}); ok {
+					// Prefer the person who authorized the integration
+					id.UserID, _ = user["id"].(string)
+					if name, _ := user["name"].(string); name != "" {
+						id.User = name
+					}
+				}
+			}
+		}
+	case "honeycomb":
+		// /1/auth describes the API key, not a user
+		id.UserID, _ = payload["id"].(string)
+		if team, ok := payload["team"].(map[string]interface{}); ok {
+			id.WorkspaceID, _ = team["slug"].(string)
+			id.Workspace, _ = team["name"].(string)
+		}
+		if env, ok := payload["environment"].(map[string]interface{}); ok {
+			if name, _ := env["name"].(string); name != "" && id.Workspace != "" {
+				id.Workspace += " / " + name
+			}
+		}
+	}
+
+	return id
+}
+
+// lookupGraphQLIdentity runs the service's identity query
+func lookupGraphQLIdentity(ctx context.Context, client *http.Client, service *config.Service, token *storage.Token) (*Identity, error) {
+	gqlResp, err := DoGraphQLContext(ctx, client, service, token, &GraphQLRequest{Query: service.IdentityQuery})
+	if err != nil {
+		return nil, err
+	}
+	if len(gqlResp.Errors) > 0 {
+		return nil, GraphQLErrors(service, gqlResp.StatusCode, gqlResp.Errors)
+	}
+
+	// Linear: { viewer { id name email } organization { id name } }
+	var data struct {
+		Viewer struct {
+			ID    string `json:"id"`
+			Name  string `json:"name"`
+			Email string `json:"email"`
+		} `json:"viewer"`
+		Organization struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"organization"`
+	}
+	if err := json.Unmarshal(gqlResp.Data, &data); err != nil {
+		return nil, fmt.Errorf("failed to parse identity response: %w", err)
+	}
+
+	id := &Identity{
+		UserID:      data.Viewer.ID,
+		User:        data.Viewer.Email,
+		WorkspaceID: data.Organization.ID,
+		Workspace:   data.Organization.Name,
+	}
+	if id.User == "" {
+		id.User = data.Viewer.Name
+	}
+	return id, nil
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/jaknapp/applink/internal/config"
+	"github.com/jaknapp/applink/internal/storage"
+)
+
+// NewRequest creates an HTTP request for a service's API with the
+// appropriate authentication headers set
+func NewRequest(service *config.Service, token *storage.Token, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	SetAuthHeaders(req, service, token)
+	return req, nil
+}
+
+// SensitiveHeaders carry credentials and are redacted wherever traffic is
+// logged or recorded. Headers set by SetAuthHeaders must be listed here.
+var SensitiveHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Honeycomb-Team",
+}
+
+// IsSensitiveHeader reports whether a header is in SensitiveHeaders
+func IsSensitiveHeader(name string) bool {
+	name = http.CanonicalHeaderKey(name)
+	for _, h := range SensitiveHeaders {
+		if h == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SetAuthHeaders adds the authentication headers a service expects
+func SetAuthHeaders(req *http.Request, service *config.Service, token *storage.Token) {
+	switch service.ID {
+	case "notion":
+		req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+		req.Header.Set("Notion-Version", "2022-06-28")
+	case "linear":
+		req.Header.Set("Authorization", token.AccessToken)
+	case "honeycomb":
+		req.Header.Set("X-Honeycomb-Team", token.AccessToken)
+	default:
+		req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	}
+}
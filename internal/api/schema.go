@@ -0,0 +1,391 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// IntrospectionQuery is the standard GraphQL introspection query
+const IntrospectionQuery = `query IntrospectionQuery {
+  __schema {
+    queryType { name }
+    mutationType { name }
+    subscriptionType { name }
+    types { ...FullType }
+    directives {
+      name
+      description
+      isRepeatable
+      locations
+      args { ...InputValue }
+    }
+  }
+}
+
+fragment FullType on __Type {
+  kind
+  name
+  description
+  fields(includeDeprecated: true) {
+    name
+    description
+    args { ...InputValue }
+    type { ...TypeRef }
+    isDeprecated
+    deprecationReason
+  }
+  inputFields { ...InputValue }
+  interfaces { ...TypeRef }
+  enumValues(includeDeprecated: true) {
+    name
+    description
+    isDeprecated
+    deprecationReason
+  }
+  possibleTypes { ...TypeRef }
+}
+
+fragment InputValue on __InputValue {
+  name
+  description
+  type { ...TypeRef }
+  defaultValue
+}
+
+fragment TypeRef on __Type {
+  kind
+  name
+  ofType {
+    kind
+    name
+    ofType {
+      kind
+      name
+      ofType {
+        kind
+        name
+        ofType {
+          kind
+          name
+          ofType {
+            kind
+            name
+            ofType {
+              kind
+              name
+              ofType { kind name }
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+// introspection result structures (only the parts needed to print SDL)
+
+type introspectionData struct {
+	Schema introspectionSchema `json:"__schema"`
+}
+
+type introspectionSchema struct {
+	QueryType        *namedRef           `json:"queryType"`
+	MutationType     *namedRef           `json:"mutationType"`
+	SubscriptionType *namedRef           `json:"subscriptionType"`
+	Types            []introspectionType `json:"types"`
+	Directives       []introspectionDir  `json:"directives"`
+}
+
+type namedRef struct {
+	Name string `json:"name"`
+}
+
+type introspectionType struct {
+	Kind          string               `json:"kind"`
+	Name          string               `json:"name"`
+	Description   string               `json:"description"`
+	Fields        []introspectionField `json:"fields"`
+	InputFields   []inputValue         `json:"inputFields"`
+	Interfaces    []typeRef            `json:"interfaces"`
+	EnumValues    []enumValue          `json:"enumValues"`
+	PossibleTypes []typeRef            `json:"possibleTypes"`
+}
+
+type introspectionField struct {
+	Name              string       `json:"name"`
+	Description       string       `json:"description"`
+	Args              []inputValue `json:"args"`
+	Type              typeRef      `json:"type"`
+	IsDeprecated      bool         `json:"isDeprecated"`
+	DeprecationReason *string      `json:"deprecationReason"`
+}
+
+type inputValue struct {
+	Name         string  `json:"name"`
+	Description  string  `json:"description"`
+	Type         typeRef `json:"type"`
+	DefaultValue *string `json:"defaultValue"`
+}
+
+type enumValue struct {
+	Name              string  `json:"name"`
+	Description       string  `json:"description"`
+	IsDeprecated      bool    `json:"isDeprecated"`
+	DeprecationReason *string `json:"deprecationReason"`
+}
+
+type introspectionDir struct {
+	Name         string       `json:"name"`
+	Description  string       `json:"description"`
+	IsRepeatable bool         `json:"isRepeatable"`
+	Locations    []string     `json:"locations"`
+	Args         []inputValue `json:"args"`
+}
+
+type typeRef struct {
+	Kind   string   `json:"kind"`
+	Name   string   `json:"name"`
+	OfType *typeRef `json:"ofType"`
+}
+
+func (t typeRef) String() string {
+	switch t.Kind {
+	case "NON_NULL":
+		if t.OfType != nil {
+			return t.OfType.String() + "!"
+		}
+	case "LIST":
+		if t.OfType != nil {
+			return "[" + t.OfType.String() + "]"
+		}
+	}
+	return t.Name
+}
+
+var builtinScalars = map[string]bool{
+	"String":  true,
+	"Int":     true,
+	"Float":   true,
+	"Boolean": true,
+	"ID":      true,
+}
+
+var builtinDirectives = map[string]bool{
+	"skip":        true,
+	"include":     true,
+	"deprecated":  true,
+	"specifiedBy": true,
+	"oneOf":       true,
+}
+
+// PrintSchema converts the data of an introspection query response into
+// GraphQL schema definition language (SDL)
+func PrintSchema(data json.RawMessage) (string, error) {
+	var result introspectionData
+	if err := json.Unmarshal(data, &result); err != nil {
+		return "", fmt.Errorf("failed to parse introspection result: %w", err)
+	}
+	schema := result.Schema
+	if schema.QueryType == nil {
+		return "", fmt.Errorf("introspection result has no query type")
+	}
+
+	var blocks []string
+
+	if def := printSchemaDefinition(schema); def != "" {
+		blocks = append(blocks, def)
+	}
+
+	directives := append([]introspectionDir(nil), schema.Directives...)
+	sort.Slice(directives, func(i, j int) bool { return directives[i].Name < directives[j].Name })
+	for _, d := range directives {
+		if builtinDirectives[d.Name] {
+			continue
+		}
+		blocks = append(blocks, printDirective(d))
+	}
+
+	types := append([]introspectionType(nil), schema.Types...)
+	sort.Slice(types, func(i, j int) bool { return types[i].Name < types[j].Name })
+	for _, t := range types {
+		if strings.HasPrefix(t.Name, "__") || builtinScalars[t.Name] {
+			continue
+		}
+		blocks = append(blocks, printType(t))
+	}
+
+	return strings.Join(blocks, "\n\n") + "\n", nil
+}
+
+// printSchemaDefinition only emits a schema block when the root types
+// don't follow the Query/Mutation/Subscription naming convention
+func printSchemaDefinition(schema introspectionSchema) string {
+	conventional := schema.QueryType.Name == "Query" &&
+		(schema.MutationType == nil || schema.MutationType.Name == "Mutation") &&
+		(schema.SubscriptionType == nil || schema.SubscriptionType.Name == "Subscription")
+	if conventional {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("schema {\n")
+	fmt.Fprintf(&b, "  query: %s\n", schema.QueryType.Name)
+	if schema.MutationType != nil {
+		fmt.Fprintf(&b, "  mutation: %s\n", schema.MutationType.Name)
+	}
+	if schema.SubscriptionType != nil {
+		fmt.Fprintf(&b, "  subscription: %s\n", schema.SubscriptionType.Name)
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+func printDirective(d introspectionDir) string {
+	var b strings.Builder
+	b.WriteString(printDescription(d.Description, ""))
+	fmt.Fprintf(&b, "directive @%s%s", d.Name, printArgs(d.Args))
+	if d.IsRepeatable {
+		b.WriteString(" repeatable")
+	}
+	fmt.Fprintf(&b, " on %s", strings.Join(d.Locations, " | "))
+	return b.String()
+}
+
+func printType(t introspectionType) string {
+	var b strings.Builder
+	b.WriteString(printDescription(t.Description, ""))
+
+	switch t.Kind {
+	case "SCALAR":
+		fmt.Fprintf(&b, "scalar %s", t.Name)
+	case "OBJECT", "INTERFACE":
+		keyword := "type"
+		if t.Kind == "INTERFACE" {
+			keyword = "interface"
+		}
+		fmt.Fprintf(&b, "%s %s%s {\n", keyword, t.Name, printImplements(t.Interfaces))
+		for i, f := range t.Fields {
+			if i > 0 && f.Description != "" {
+				b.WriteString("\n")
+			}
+			b.WriteString(printDescription(f.Description, "  "))
+			fmt.Fprintf(&b, "  %s%s: %s%s\n", f.Name, printArgs(f.Args), f.Type, printDeprecated(f.IsDeprecated, f.DeprecationReason))
+		}
+		b.WriteString("}")
+	case "UNION":
+		members := make([]string, len(t.PossibleTypes))
+		for i, m := range t.PossibleTypes {
+			members[i] = m.String()
+		}
+		fmt.Fprintf(&b, "union %s = %s", t.Name, strings.Join(members, " | "))
+	case "ENUM":
+		fmt.Fprintf(&b, "enum %s {\n", t.Name)
+		for i, v := range t.EnumValues {
+			if i > 0 && v.Description != "" {
+				b.WriteString("\n")
+			}
+			b.WriteString(printDescription(v.Description, "  "))
+			fmt.Fprintf(&b, "  %s%s\n", v.Name, printDeprecated(v.IsDeprecated, v.DeprecationReason))
+		}
+		b.WriteString("}")
+	case "INPUT_OBJECT":
+		fmt.Fprintf(&b, "input %s {\n", t.Name)
+		for i, f := range t.InputFields {
+			if i > 0 && f.Description != "" {
+				b.WriteString("\n")
+			}
+			b.WriteString(printDescription(f.Description, "  "))
+			fmt.Fprintf(&b, "  %s\n", printInputValue(f))
+		}
+		b.WriteString("}")
+	default:
+		fmt.Fprintf(&b, "# unsupported type kind %s: %s", t.Kind, t.Name)
+	}
+
+	return b.String()
+}
+
+func printImplements(interfaces []typeRef) string {
+	if len(interfaces) == 0 {
+		return ""
+	}
+	names := make([]string, len(interfaces))
+	for i, iface := range interfaces {
+		names[i] = iface.String()
+	}
+	return " implements " + strings.Join(names, " & ")
+}
+
+func printArgs(args []inputValue) string {
+	if len(args) == 0 {
+		return ""
+	}
+
+	// Keep short argument lists on one line, like graphql-js does
+	hasDescription := false
+	for _, a := range args {
+		if a.Description != "" {
+			hasDescription = true
+			break
+		}
+	}
+	if !hasDescription {
+		parts := make([]string, len(args))
+		for i, a := range args {
+			parts[i] = printInputValue(a)
+		}
+		return "(" + strings.Join(parts, ", ") + ")"
+	}
+
+	var b strings.Builder
+	b.WriteString("(\n")
+	for _, a := range args {
+		b.WriteString(printDescription(a.Description, "    "))
+		fmt.Fprintf(&b, "    %s\n", printInputValue(a))
+	}
+	b.WriteString("  )")
+	return b.String()
+}
+
+func printInputValue(v inputValue) string {
+	s := fmt.Sprintf("%s: %s", v.Name, v.Type)
+	if v.DefaultValue != nil {
+		s += " = " + *v.DefaultValue
+	}
+	return s
+}
+
+func printDeprecated(deprecated bool, reason *string) string {
+	if !deprecated {
+		return ""
+	}
+	if reason == nil || *reason == "" || *reason == "No longer supported" {
+		return " @deprecated"
+	}
+	quoted, _ := json.Marshal(*reason)
+	return fmt.Sprintf(" @deprecated(reason: %s)", quoted)
+}
+
+func printDescription(description, indent string) string {
+	if description == "" {
+		return ""
+	}
+	escaped := strings.ReplaceAll(description, `"""`, `\"""`)
+	if !strings.Contains(escaped, "\n") {
+		return fmt.Sprintf("%s\"\"\"%s\"\"\"\n", indent, escaped)
+	}
+	lines := strings.Split(escaped, "\n")
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\"\"\"\n", indent)
+	for _, line := range lines {
+		if line == "" {
+			b.WriteString("\n")
+			continue
+		}
+		fmt.Fprintf(&b, "%s%s\n", indent, line)
+	}
+	fmt.Fprintf(&b, "%s\"\"\"\n", indent)
+	return b.String()
+}
@@ -5,6 +5,10 @@ import (
 	"runtime"
 )
 
+// browserOpener opens the authorization URL during the OAuth flow. Tests
+// replace it so no browser is launched.
+var browserOpener = openBrowser
+
 // openBrowser opens the specified URL in the default browser
 func openBrowser(url string) error {
 	var cmd *exec.Cmd
@@ -10,56 +10,220 @@ import (
 	"encoding/pem"
 	"fmt"
 	"math/big"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/jaknapp/applink/internal/certs"
 )
 
-// startCallbackServer starts a local HTTP/HTTPS server to receive OAuth callbacks
-func startCallbackServer(port int, expectedState string, codeChan chan<- string, errChan chan<- error, useTLS bool) *http.Server {
-	mux := http.NewServeMux()
-
-	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
-		// Check for error
-		if errParam := r.URL.Query().Get("error"); errParam != "" {
-			errDesc := r.URL.Query().Get("error_description")
-			w.Header().Set("Content-Type", "text/html; charset=utf-8")
-			w.WriteHeader(http.StatusBadRequest)
-			fmt.Fprintf(w, errorHTML, errParam, errDesc)
-			errChan <- fmt.Errorf("%s: %s", errParam, errDesc)
-			return
+// exchangeWaitTimeout bounds how long the browser waits for the token
+// exchange before the success page is shown without account details
+const exchangeWaitTimeout = 30 * time.Second
+
+// NormalizeRedirectHost validates a redirect URI host. Only loopback hosts
+// are allowed; an empty host means "localhost".
+func NormalizeRedirectHost(host string) (string, error) {
+	switch host {
+	case "", "localhost":
+		return "localhost", nil
+	case "127.0.0.1":
+		return host, nil
+	case "::1", "[::1]":
+		return "[::1]", nil
+	default:
+		return "", fmt.Errorf("unsupported redirect host %q: use localhost, 127.0.0.1 or [::1]", host)
+	}
+}
+
+// listenCallback binds the callback server to loopback addresses only, on
+// the first of the given ports that is free. For "localhost" it listens on
+// both 127.0.0.1 and ::1 (when available), since browsers may resolve
+// localhost to either.
+func listenCallback(host string, ports []int) ([]net.Listener, int, error) {
+	if len(ports) == 0 {
+		return nil, 0, fmt.Errorf("no callback ports configured")
+	}
+
+	primary, secondary := "127.0.0.1", "::1"
+	if host == "[::1]" {
+		primary = "::1"
+	}
+
+	var failures []string
+	for _, port := range ports {
+		listener, err := net.Listen("tcp", net.JoinHostPort(primary, strconv.Itoa(port)))
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("  %d: %v", port, err))
+			continue
 		}
+		listeners := []net.Listener{listener}
+		bound := listener.Addr().(*net.TCPAddr).Port
 
-		// Verify state
-		state := r.URL.Query().Get("state")
-		if state != expectedState {
-			w.Header().Set("Content-Type", "text/html; charset=utf-8")
-			w.WriteHeader(http.StatusBadRequest)
-			fmt.Fprintf(w, errorHTML, "Invalid state", "State parameter mismatch")
-			errChan <- fmt.Errorf("state mismatch: possible CSRF attack")
-			return
+		// Best effort: IPv6 may be disabled on this machine
+		if host == "localhost" {
+			if l6, err := net.Listen("tcp", net.JoinHostPort(secondary, strconv.Itoa(bound))); err == nil {
+				listeners = append(listeners, l6)
+			}
 		}
 
-		// Get authorization code
-		code := r.URL.Query().Get("code")
-		if code == "" {
-			w.Header().Set("Content-Type", "text/html; charset=utf-8")
-			w.WriteHeader(http.StatusBadRequest)
-			fmt.Fprintf(w, errorHTML, "Missing code", "No authorization code received")
-			errChan <- fmt.Errorf("no authorization code in callback")
+		return listeners, bound, nil
+	}
+
+	return nil, 0, fmt.Errorf(`could not start the callback server on any registered port:
+%s
+
+Free one of these ports, or use another one registered as a redirect URI
+with your OAuth app: applink login <service> --port <port>`, strings.Join(failures, "\n"))
+}
+
+func closeListeners(listeners []net.Listener) {
+	for _, l := range listeners {
+		l.Close()
+	}
+}
+
+// callbackResult is the outcome of the OAuth redirect
+type callbackResult struct {
+	code string
+	err  error
+}
+
+// loginOutcome is the result of exchanging the authorization code
+type loginOutcome struct {
+	token *Token
+	err   error
+}
+
+// callbackHandler receives the OAuth redirect. It is single-use: only the
+// first request to /callback carrying the expected state is accepted, so
+// browser retries, favicon requests and port scanners can neither block
+// the handler nor end the login.
+type callbackHandler struct {
+	expectedState   string
+	serviceName     string
+	pages           *callbackPages
+	successRedirect string
+
+	results      chan callbackResult
+	outcomes     chan loginOutcome
+	done         atomic.Bool
+	completeOnce sync.Once
+}
+
+func newCallbackHandler(expectedState, serviceName string, pages *callbackPages, successRedirect string) *callbackHandler {
+	return &callbackHandler{
+		expectedState:   expectedState,
+		serviceName:     serviceName,
+		pages:           pages,
+		successRedirect: successRedirect,
+		results:         make(chan callbackResult, 1),
+		outcomes:        make(chan loginOutcome, 1),
+	}
+}
+
+// Results delivers exactly one callback result
+func (h *callbackHandler) Results() <-chan callbackResult {
+	return h.results
+}
+
+// complete reports the outcome of the token exchange to the waiting
+// browser request. Only the first call has an effect.
+func (h *callbackHandler) complete(token *Token, err error) {
+	h.completeOnce.Do(func() {
+		h.outcomes <- loginOutcome{token: token, err: err}
+	})
+}
+
+func (h *callbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/favicon.ico":
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case r.URL.Path != "/callback":
+		http.NotFound(w, r)
+		return
+	case r.Method != http.MethodGet:
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+
+	// Requests without the right state don't come from our authorization
+	// request; reject them without ending the login
+	if query.Get("state") != h.expectedState {
+		h.writeError(w, http.StatusBadRequest, "Invalid state", "State parameter mismatch")
+		return
+	}
+
+	if !h.done.CompareAndSwap(false, true) {
+		h.writeError(w, http.StatusConflict, "Already used", "This login callback has already been handled. Return to the terminal.")
+		return
+	}
+
+	// Check for error
+	if errParam := query.Get("error"); errParam != "" {
+		errDesc := query.Get("error_description")
+		h.writeError(w, http.StatusBadRequest, errParam, errDesc)
+		h.results <- callbackResult{err: fmt.Errorf("%s: %s", errParam, errDesc)}
+		return
+	}
+
+	// Get authorization code
+	code := query.Get("code")
+	if code == "" {
+		h.writeError(w, http.StatusBadRequest, "Missing code", "No authorization code received")
+		h.results <- callbackResult{err: fmt.Errorf("no authorization code in callback")}
+		return
+	}
+
+	h.results <- callbackResult{code: code}
+
+	// Keep the browser waiting until the code has been exchanged, so the
+	// page can tell whether it worked and which account was connected
+	select {
+	case outcome := <-h.outcomes:
+		if outcome.err != nil {
+			h.writeError(w, http.StatusBadGateway, "Login failed", outcome.err.Error())
 			return
 		}
+		if h.successRedirect != "" {
+			http.Redirect(w, r, h.successRedirect, http.StatusFound)
+			return
+		}
+		h.pages.writeSuccess(w, pageData{
+			Service:   h.serviceName,
+			Account:   outcome.token.User,
+			Workspace: outcome.token.Workspace,
+		})
+	case <-time.After(exchangeWaitTimeout):
+		h.pages.writeSuccess(w, pageData{Service: h.serviceName})
+	case <-r.Context().Done():
+	}
+}
 
-		// Success!
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		fmt.Fprint(w, successHTML)
-		codeChan <- code
+func (h *callbackHandler) writeError(w http.ResponseWriter, status int, title, description string) {
+	h.pages.writeError(w, status, pageData{
+		Service:          h.serviceName,
+		Error:            title,
+		ErrorDescription: description,
 	})
+}
 
+// startCallbackServer starts a local HTTP/HTTPS server to receive OAuth
+// callbacks. HTTPS uses a certificate signed by the local CA if useCA is
+// set and the CA exists. Serve errors are reported on the returned channel,
+// which never blocks the server goroutines.
+func startCallbackServer(listeners []net.Listener, handler http.Handler, useTLS, useCA bool) (*http.Server, <-chan error, error) {
 	server := &http.Server{
-		Addr:    fmt.Sprintf(":%d", port),
-		Handler: mux,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
 	}
 
 	if useTLS {
@@ -68,8 +232,8 @@ func startCallbackServer(port int, expectedState string, codeChan chan<- string,
 		var err error
 		var usingCA bool
 
-		if exists, _ := certs.CAExists(); exists {
-			tlsCert, err = certs.GenerateServerCert()
+		if exists, _ := certs.CAExists(); exists && useCA {
+			tlsCert, err = certs.ServerCert()
 			if err == nil {
 				usingCA = true
 			}
@@ -79,31 +243,33 @@ func startCallbackServer(port int, expectedState string, codeChan chan<- string,
 		if !usingCA {
 			tlsCert, err = generateSelfSignedCert()
 			if err != nil {
-				errChan <- fmt.Errorf("failed to generate TLS certificate: %w", err)
-				return nil
+				return nil, nil, fmt.Errorf("failed to generate TLS certificate: %w", err)
 			}
 		}
 
 		server.TLSConfig = &tls.Config{
 			Certificates: []tls.Certificate{tlsCert},
 		}
+	}
 
-		go func() {
-			// Use ListenAndServeTLS with empty cert/key paths since we're using TLSConfig
-			if err := server.ListenAndServeTLS("", ""); err != http.ErrServerClosed {
-				errChan <- fmt.Errorf("callback server error: %w", err)
+	serveErrs := make(chan error, len(listeners))
+	for _, listener := range listeners {
+		go func(l net.Listener) {
+			var err error
+			if useTLS {
+				// Use ServeTLS with empty cert/key paths since we're using TLSConfig
+				err = server.ServeTLS(l, "", "")
+			} else {
+				// Plain HTTP is allowed for localhost per RFC 8252
+				err = server.Serve(l)
 			}
-		}()
-	} else {
-		// Start plain HTTP server (allowed for localhost per RFC 8252)
-		go func() {
-			if err := server.ListenAndServe(); err != http.ErrServerClosed {
-				errChan <- fmt.Errorf("callback server error: %w", err)
+			if err != http.ErrServerClosed {
+				serveErrs <- fmt.Errorf("callback server error: %w", err)
 			}
-		}()
+		}(listener)
 	}
 
-	return server
+	return server, serveErrs, nil
 }
 
 // generateSelfSignedCert creates a self-signed TLS certificate for localhost
@@ -132,6 +298,7 @@ func generateSelfSignedCert() (tls.Certificate, error) {
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
 		BasicConstraintsValid: true,
 		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
 	}
 
 	// Create certificate
@@ -151,105 +318,3 @@ func generateSelfSignedCert() (tls.Certificate, error) {
 	// Create TLS certificate
 	return tls.X509KeyPair(certPEM, keyPEM)
 }
-
-const successHTML = `<!DOCTYPE html>
-<html>
-<head>
-    <meta charset="UTF-8">
-    <title>Authentication Successful</title>
-    <style>
-        body {
-            font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
-            display: flex;
-            justify-content: center;
-            align-items: center;
-            height: 100vh;
-            margin: 0;
-            background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
-        }
-        .card {
-            background: white;
-            padding: 3rem;
-            border-radius: 1rem;
-            box-shadow: 0 20px 40px rgba(0,0,0,0.2);
-            text-align: center;
-            max-width: 400px;
-        }
-        .checkmark {
-            font-size: 4rem;
-            margin-bottom: 1rem;
-        }
-        h1 {
-            color: #1a1a2e;
-            margin: 0 0 0.5rem 0;
-        }
-        p {
-            color: #666;
-            margin: 0;
-        }
-    </style>
-</head>
-<body>
-    <div class="card">
-        <div class="checkmark">✓</div>
-        <h1>Authentication Successful</h1>
-        <p>You can close this window and return to the terminal.</p>
-    </div>
-</body>
-</html>`
-
-const errorHTML = `<!DOCTYPE html>
-<html>
-<head>
-    <meta charset="UTF-8">
-    <title>Authentication Failed</title>
-    <style>
-        body {
-            font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
-            display: flex;
-            justify-content: center;
-            align-items: center;
-            height: 100vh;
-            margin: 0;
-            background: linear-gradient(135deg, #f093fb 0%, #f5576c 100%);
-        }
-        .card {
-            background: white;
-            padding: 3rem;
-            border-radius: 1rem;
-            box-shadow: 0 20px 40px rgba(0,0,0,0.2);
-            text-align: center;
-            max-width: 400px;
-        }
-        .error-icon {
-            font-size: 4rem;
-            margin-bottom: 1rem;
-        }
-        h1 {
-            color: #1a1a2e;
-            margin: 0 0 0.5rem 0;
-        }
-        p {
-            color: #666;
-            margin: 0;
-        }
-        .error-details {
-            background: #f5f5f5;
-            padding: 1rem;
-            border-radius: 0.5rem;
-            margin-top: 1rem;
-            font-family: monospace;
-            font-size: 0.9rem;
-            color: #c0392b;
-        }
-    </style>
-</head>
-<body>
-    <div class="card">
-        <div class="error-icon">✗</div>
-        <h1>Authentication Failed</h1>
-        <p>Something went wrong during authentication.</p>
-        <div class="error-details">%s: %s</div>
-    </div>
-</body>
-</html>`
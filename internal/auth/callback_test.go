@@ -0,0 +1,287 @@
+package auth
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaknapp/applink/internal/config"
+)
+
+const testState = "test-state"
+
+func newTestHandler(t *testing.T) (*callbackHandler, *httptest.Server) {
+	t.Helper()
+	pages, err := loadCallbackPages("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := newCallbackHandler(testState, "Test", pages, "")
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	// Release a request still waiting for the outcome so Close doesn't hang
+	t.Cleanup(func() { handler.complete(nil, context.Canceled) })
+	return handler, server
+}
+
+func callbackURL(server *httptest.Server, query url.Values) string {
+	return server.URL + "/callback?" + query.Encode()
+}
+
+// receiveResult waits for the handler to report a callback result
+func receiveResult(t *testing.T, h *callbackHandler) callbackResult {
+	t.Helper()
+	select {
+	case result := <-h.Results():
+		return result
+	case <-time.After(5 * time.Second):
+		t.Fatal("no callback result")
+		return callbackResult{}
+	}
+}
+
+// assertNoResult checks that the handler reported nothing
+func assertNoResult(t *testing.T, h *callbackHandler) {
+	t.Helper()
+	select {
+	case result := <-h.Results():
+		t.Fatalf("unexpected callback result %+v", result)
+	default:
+	}
+}
+
+func TestCallbackHandlerSingleUse(t *testing.T) {
+	h, server := newTestHandler(t)
+	query := url.Values{"state": {testState}, "code": {"the-code"}}
+
+	// The first request waits for the token exchange, so send it in the
+	// background and complete the login once the code arrives
+	first := make(chan *http.Response, 1)
+	go func() {
+		resp, err := http.Get(callbackURL(server, query))
+		if err != nil {
+			t.Error(err)
+			first <- nil
+			return
+		}
+		first <- resp
+	}()
+
+	result := receiveResult(t, h)
+	if result.err != nil || result.code != "the-code" {
+		t.Fatalf("got result %+v, want code the-code", result)
+	}
+
+	// Retries must not deliver another result or end the login
+	resp, err := http.Get(callbackURL(server, query))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("second callback: got status %d, want %d", resp.StatusCode, http.StatusConflict)
+	}
+	assertNoResult(t, h)
+
+	h.complete(&Token{User: "someone"}, nil)
+	resp = <-first
+	if resp == nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("first callback: got status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestCallbackHandlerRejectsNonGet(t *testing.T) {
+	h, server := newTestHandler(t)
+
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodDelete} {
+		req, err := http.NewRequest(method, callbackURL(server, url.Values{"state": {testState}, "code": {"c"}}), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("%s: got status %d, want %d", method, resp.StatusCode, http.StatusMethodNotAllowed)
+		}
+		if allow := resp.Header.Get("Allow"); allow != http.MethodGet {
+			t.Errorf("%s: got Allow %q, want GET", method, allow)
+		}
+	}
+	assertNoResult(t, h)
+}
+
+func TestCallbackHandlerOtherPaths(t *testing.T) {
+	h, server := newTestHandler(t)
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/favicon.ico", http.StatusNoContent},
+		{"/", http.StatusNotFound},
+		{"/callback/extra", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(server.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s: got status %d, want %d", tt.path, resp.StatusCode, tt.want)
+		}
+	}
+	assertNoResult(t, h)
+}
+
+func TestCallbackHandlerStateMismatch(t *testing.T) {
+	h, server := newTestHandler(t)
+
+	for _, query := range []url.Values{
+		{"state": {"wrong"}, "code": {"c"}},
+		{"code": {"c"}},
+		{"state": {"wrong"}, "error": {"access_denied"}},
+	} {
+		resp, err := http.Get(callbackURL(server, query))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%v: got status %d, want %d", query, resp.StatusCode, http.StatusBadRequest)
+		}
+	}
+	assertNoResult(t, h)
+
+	// A mismatched state doesn't use up the handler
+	resp, err := http.Get(callbackURL(server, url.Values{"state": {testState}, "error": {"access_denied"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if result := receiveResult(t, h); result.err == nil || !strings.Contains(result.err.Error(), "access_denied") {
+		t.Errorf("got result %+v, want access_denied error", result)
+	}
+}
+
+func TestCallbackHandlerMissingCode(t *testing.T) {
+	h, server := newTestHandler(t)
+
+	resp, err := http.Get(callbackURL(server, url.Values{"state": {testState}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+	if result := receiveResult(t, h); result.err == nil {
+		t.Errorf("got result %+v, want an error", result)
+	}
+}
+
+// runFlow starts DoOAuthFlow without a browser and returns the callback
+// address it listens on and a channel with its error
+func runFlow(t *testing.T, ctx context.Context, timeout time.Duration) (string, <-chan error) {
+	t.Helper()
+
+	authURLs := make(chan string, 1)
+	browserOpener = func(u string) error {
+		authURLs <- u
+		return nil
+	}
+	t.Cleanup(func() { browserOpener = openBrowser })
+
+	service := &config.Service{ID: "test", Name: "Test", AuthURL: "https://auth.example.com/authorize"}
+	opts := FlowOptions{Ports: []int{0}, RedirectHost: "127.0.0.1", Timeout: timeout}
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := DoOAuthFlow(ctx, service, config.ClientCredentials{ClientID: "id"}, opts)
+		errs <- err
+	}()
+
+	var authURL string
+	select {
+	case authURL = <-authURLs:
+	case err := <-errs:
+		t.Fatalf("flow ended before opening the browser: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("flow didn't open the browser")
+	}
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	redirect, err := url.Parse(u.Query().Get("redirect_uri"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return redirect.Host, errs
+}
+
+// waitFlow returns the flow's error
+func waitFlow(t *testing.T, errs <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-errs:
+		return err
+	case <-time.After(10 * time.Second):
+		t.Fatal("flow didn't return")
+		return nil
+	}
+}
+
+// assertServerStopped checks that nothing listens on addr any more
+func assertServerStopped(t *testing.T, addr string) {
+	t.Helper()
+	conn, err := net.DialTimeout("tcp", addr, time.Second)
+	if err == nil {
+		conn.Close()
+		t.Errorf("callback server on %s is still listening", addr)
+	}
+}
+
+func TestDoOAuthFlowCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	addr, errs := runFlow(t, ctx, time.Minute)
+
+	// The server answers while the flow waits
+	resp, err := http.Get("http://" + addr + "/favicon.ico")
+	if err != nil {
+		t.Fatalf("callback server isn't running: %v", err)
+	}
+	resp.Body.Close()
+
+	cancel()
+	err = waitFlow(t, errs)
+	if err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Errorf("got error %v, want cancellation", err)
+	}
+	assertServerStopped(t, addr)
+}
+
+func TestDoOAuthFlowTimeout(t *testing.T) {
+	addr, errs := runFlow(t, context.Background(), 100*time.Millisecond)
+
+	err := waitFlow(t, errs)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("got error %v, want timeout", err)
+	}
+	assertServerStopped(t, addr)
+}
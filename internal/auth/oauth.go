@@ -19,8 +19,60 @@ import (
 // Token is an alias for storage.Token for convenience
 type Token = storage.Token
 
-// DoOAuthFlow performs the OAuth 2.0 authorization code flow
-func DoOAuthFlow(service *config.Service, creds config.ClientCredentials, port int) (*Token, error) {
+// httpTransport is used for token exchange requests
+var httpTransport http.RoundTripper = http.DefaultTransport
+
+// SetTransport sets the HTTP transport used to talk to OAuth providers
+// (e.g. to record traffic for debugging)
+func SetTransport(t http.RoundTripper) {
+	httpTransport = t
+}
+
+// FlowOptions configures the local side of an OAuth flow
+type FlowOptions struct {
+	// Ports to try for the callback server, in order. Port 0 binds a
+	// free port chosen by the system.
+	Ports []int
+
+	// RedirectHost is the host of the redirect URI: "localhost" (the
+	// default), "127.0.0.1" or "[::1]". The callback server only ever
+	// listens on loopback addresses.
+	RedirectHost string
+
+	// Timeout is how long to wait for the browser callback (default 5 minutes)
+	Timeout time.Duration
+
+	// Scopes to request; nil means the service's default scopes
+	Scopes []string
+
+	// BotScopes to request for services that issue bot tokens (Slack);
+	// nil means the service's default bot scopes
+	BotScopes []string
+
+	// TemplateDir may contain success.html and error.html to replace the
+	// built-in callback pages
+	TemplateDir string
+
+	// SuccessRedirectURL, if set, is where the browser is sent after a
+	// successful login instead of the success page
+	SuccessRedirectURL string
+
+	// SelfSignedCert serves HTTPS callbacks with a throwaway self-signed
+	// certificate instead of one signed by the local CA
+	SelfSignedCert bool
+
+	// Identify, if set, is called with the new token before the browser is
+	// answered, so the success page can show the connected account
+	Identify func(ctx context.Context, token *Token)
+}
+
+// DefaultCallbackTimeout is how long DoOAuthFlow waits for the callback
+// unless FlowOptions.Timeout is set
+const DefaultCallbackTimeout = 5 * time.Minute
+
+// DoOAuthFlow performs the OAuth 2.0 authorization code flow. Cancelling
+// ctx (e.g. on Ctrl-C) shuts the callback server down and aborts the login.
+func DoOAuthFlow(ctx context.Context, service *config.Service, creds config.ClientCredentials, opts FlowOptions) (*Token, error) {
 	// Determine if we need TLS
 	// Most OAuth providers (including Slack) allow HTTP for localhost per RFC 8252
 	// We default to HTTP for localhost to avoid certificate issues
@@ -32,48 +84,105 @@ func DoOAuthFlow(service *config.Service, creds config.ClientCredentials, port i
 		return nil, fmt.Errorf("failed to generate state: %w", err)
 	}
 
+	host, err := NormalizeRedirectHost(opts.RedirectHost)
+	if err != nil {
+		return nil, err
+	}
+
+	// Bind the callback port first; the redirect URI depends on it
+	listeners, port, err := listenCallback(host, opts.Ports)
+	if err != nil {
+		return nil, err
+	}
+	redirect := redirectURI(host, port, useTLS)
+
 	// Build authorization URL
-	authURL, err := buildAuthURL(service, creds.ClientID, port, state, useTLS)
+	scopes := opts.Scopes
+	if scopes == nil {
+		scopes = service.Scopes
+	}
+	botScopes := opts.BotScopes
+	if botScopes == nil {
+		botScopes = service.BotScopes
+	}
+	authURL, err := buildAuthURL(service, creds.ClientID, redirect, state, scopes, botScopes)
 	if err != nil {
+		closeListeners(listeners)
 		return nil, fmt.Errorf("failed to build auth URL: %w", err)
 	}
 
+	pages, err := loadCallbackPages(opts.TemplateDir)
+	if err != nil {
+		closeListeners(listeners)
+		return nil, err
+	}
+
 	// Start callback server
-	codeChan := make(chan string, 1)
-	errChan := make(chan error, 1)
-	server := startCallbackServer(port, state, codeChan, errChan, useTLS)
+	handler := newCallbackHandler(state, service.Name, pages, opts.SuccessRedirectURL)
+	server, serveErrs, err := startCallbackServer(listeners, handler, useTLS, !opts.SelfSignedCert)
+	if err != nil {
+		closeListeners(listeners)
+		return nil, err
+	}
+	defer shutdownServer(server)
+	// Release a browser request still waiting for the outcome if we bail out
+	defer handler.complete(nil, fmt.Errorf("login was aborted"))
 
 	// Open browser
 	fmt.Printf("Opening browser for authentication...\n")
 	fmt.Printf("If the browser doesn't open, visit:\n%s\n\n", authURL)
-	if err := openBrowser(authURL); err != nil {
+	if err := browserOpener(authURL); err != nil {
 		fmt.Printf("Failed to open browser: %v\n", err)
 	}
 
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultCallbackTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
 	// Wait for callback
 	var code string
 	select {
-	case code = <-codeChan:
-		// Success
-	case err := <-errChan:
-		shutdownServer(server)
+	case result := <-handler.Results():
+		if result.err != nil {
+			return nil, result.err
+		}
+		code = result.code
+	case err := <-serveErrs:
 		return nil, err
-	case <-time.After(5 * time.Minute):
-		shutdownServer(server)
-		return nil, fmt.Errorf("authentication timed out")
+	case <-timer.C:
+		return nil, fmt.Errorf("authentication timed out after %s", timeout)
+	case <-ctx.Done():
+		return nil, fmt.Errorf("authentication cancelled")
 	}
 
-	shutdownServer(server)
-
 	// Exchange code for token
-	token, err := exchangeCode(service, creds, code, port, useTLS)
+	token, err := exchangeCode(ctx, service, creds, code, redirect)
 	if err != nil {
-		return nil, fmt.Errorf("failed to exchange code: %w", err)
+		err = fmt.Errorf("failed to exchange code: %w", err)
+		handler.complete(nil, err)
+		return nil, err
 	}
+	token.RequestedScopes = scopes
+	if token.Bot != nil {
+		token.Bot.RequestedScopes = botScopes
+	}
+	if opts.Identify != nil {
+		opts.Identify(ctx, token)
+	}
+	handler.complete(token, nil)
 
 	return token, nil
 }
 
+// SupportsBotToken reports whether a service can issue a bot token
+// alongside the user token
+func SupportsBotToken(service *config.Service) bool {
+	return service.ID == "slack"
+}
+
 // serviceRequiresTLS checks if a service requires HTTPS for redirect URIs
 func serviceRequiresTLS(service *config.Service) bool {
 	switch service.ID {
@@ -94,46 +203,48 @@ func generateState() (string, error) {
 	return base64.URLEncoding.EncodeToString(b), nil
 }
 
-func buildAuthURL(service *config.Service, clientID string, port int, state string, useTLS bool) (string, error) {
-	u, err := url.Parse(service.AuthURL)
-	if err != nil {
-		return "", err
-	}
-
+// redirectURI returns the OAuth redirect URI for the local callback server
+func redirectURI(host string, port int, useTLS bool) string {
 	scheme := "http"
 	if useTLS {
 		scheme = "https"
 	}
+	return fmt.Sprintf("%s://%s:%d/callback", scheme, host, port)
+}
+
+func buildAuthURL(service *config.Service, clientID, redirect, state string, scopes, botScopes []string) (string, error) {
+	u, err := url.Parse(service.AuthURL)
+	if err != nil {
+		return "", err
+	}
 
 	q := u.Query()
 	q.Set("client_id", clientID)
-	q.Set("redirect_uri", fmt.Sprintf("%s://localhost:%d/callback", scheme, port))
+	q.Set("redirect_uri", redirect)
 	q.Set("response_type", "code")
 	q.Set("state", state)
 
-	if len(service.Scopes) > 0 {
-		// Slack uses user_scope for user tokens, not scope
-		if service.ID == "slack" {
-			q.Set("user_scope", strings.Join(service.Scopes, ","))
-		} else {
-			q.Set("scope", strings.Join(service.Scopes, " "))
+	// Slack uses user_scope for user tokens and scope for the bot token
+	if service.ID == "slack" {
+		if len(scopes) > 0 {
+			q.Set("user_scope", strings.Join(scopes, ","))
+		}
+		if len(botScopes) > 0 {
+			q.Set("scope", strings.Join(botScopes, ","))
 		}
+	} else if len(scopes) > 0 {
+		q.Set("scope", strings.Join(scopes, " "))
 	}
 
 	u.RawQuery = q.Encode()
 	return u.String(), nil
 }
 
-func exchangeCode(service *config.Service, creds config.ClientCredentials, code string, port int, useTLS bool) (*Token, error) {
+func exchangeCode(ctx context.Context, service *config.Service, creds config.ClientCredentials, code, redirect string) (*Token, error) {
 	data := url.Values{}
 	data.Set("grant_type", "authorization_code")
 	data.Set("code", code)
-
-	scheme := "http"
-	if useTLS {
-		scheme = "https"
-	}
-	data.Set("redirect_uri", fmt.Sprintf("%s://localhost:%d/callback", scheme, port))
+	data.Set("redirect_uri", redirect)
 
 	// Different services have different auth requirements
 	var req *http.Request
@@ -142,7 +253,7 @@ func exchangeCode(service *config.Service, creds config.ClientCredentials, code
 	switch service.ID {
 	case "notion":
 		// Notion uses Basic auth for token exchange
-		req, err = http.NewRequest("POST", service.TokenURL, strings.NewReader(data.Encode()))
+		req, err = http.NewRequestWithContext(ctx, "POST", service.TokenURL, strings.NewReader(data.Encode()))
 		if err != nil {
 			return nil, err
 		}
@@ -152,7 +263,7 @@ func exchangeCode(service *config.Service, creds config.ClientCredentials, code
 		// Slack wants credentials in the body
 		data.Set("client_id", creds.ClientID)
 		data.Set("client_secret", creds.ClientSecret)
-		req, err = http.NewRequest("POST", service.TokenURL, strings.NewReader(data.Encode()))
+		req, err = http.NewRequestWithContext(ctx, "POST", service.TokenURL, strings.NewReader(data.Encode()))
 		if err != nil {
 			return nil, err
 		}
@@ -160,7 +271,7 @@ func exchangeCode(service *config.Service, creds config.ClientCredentials, code
 		// Standard OAuth2: credentials in body
 		data.Set("client_id", creds.ClientID)
 		data.Set("client_secret", creds.ClientSecret)
-		req, err = http.NewRequest("POST", service.TokenURL, strings.NewReader(data.Encode()))
+		req, err = http.NewRequestWithContext(ctx, "POST", service.TokenURL, strings.NewReader(data.Encode()))
 		if err != nil {
 			return nil, err
 		}
@@ -168,7 +279,7 @@ func exchangeCode(service *config.Service, creds config.ClientCredentials, code
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := &http.Client{Timeout: 30 * time.Second, Transport: httpTransport}
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
@@ -205,10 +316,33 @@ func parseTokenResponse(service *config.Service, body []byte) (*Token, error) {
 		}
 		if authedUser, ok := rawResp["authed_user"].(map[string]interface{}); ok {
 			token.AccessToken, _ = authedUser["access_token"].(string)
+			token.RefreshToken, _ = authedUser["refresh_token"].(string)
+			token.TokenType, _ = authedUser["token_type"].(string)
 			token.Scope, _ = authedUser["scope"].(string)
+			token.UserID, _ = authedUser["id"].(string)
+			token.ExpiresAt = expiresAt(authedUser)
+		}
+		// The top-level token is the bot token, present if bot scopes were requested
+		if botToken, _ := rawResp["access_token"].(string); botToken != "" {
+			token.Bot = &storage.BotToken{AccessToken: botToken, ExpiresAt: expiresAt(rawResp)}
+			token.Bot.RefreshToken, _ = rawResp["refresh_token"].(string)
+			token.Bot.Scope, _ = rawResp["scope"].(string)
+			token.Bot.UserID, _ = rawResp["bot_user_id"].(string)
 		}
 		if team, ok := rawResp["team"].(map[string]interface{}); ok {
 			token.TeamID, _ = team["id"].(string)
+			token.Workspace, _ = team["name"].(string)
+		}
+		if enterprise, ok := rawResp["enterprise"].(map[string]interface{}); ok {
+			token.EnterpriseID, _ = enterprise["id"].(string)
+			token.EnterpriseName, _ = enterprise["name"].(string)
+		}
+		if webhook, ok := rawResp["incoming_webhook"].(map[string]interface{}); ok {
+			token.IncomingWebhook = &storage.IncomingWebhook{}
+			token.IncomingWebhook.URL, _ = webhook["url"].(string)
+			token.IncomingWebhook.Channel, _ = webhook["channel"].(string)
+			token.IncomingWebhook.ChannelID, _ = webhook["channel_id"].(string)
+			token.IncomingWebhook.ConfigurationURL, _ = webhook["configuration_url"].(string)
 		}
 	default:
 		// Standard OAuth2 response
@@ -217,19 +351,35 @@ func parseTokenResponse(service *config.Service, body []byte) (*Token, error) {
 		token.TokenType, _ = rawResp["token_type"].(string)
 		token.Scope, _ = rawResp["scope"].(string)
 
-		// Handle expires_in
-		if expiresIn, ok := rawResp["expires_in"].(float64); ok && expiresIn > 0 {
-			token.ExpiresAt = time.Now().Add(time.Duration(expiresIn) * time.Second)
+		token.ExpiresAt = expiresAt(rawResp)
+
+		// Notion names the workspace and the user who authorized it
+		if service.ID == "notion" {
+			token.Workspace, _ = rawResp["workspace_name"].(string)
+			if owner, ok := rawResp["owner"].(map[string]interface{}); ok {
+				if user, ok := owner["user"].(map[string]interface{}); ok {
+					token.User, _ = user["name"].(string)
+				}
+			}
 		}
 	}
 
-	if token.AccessToken == "" {
+	if token.AccessToken == "" && token.Bot == nil {
 		return nil, fmt.Errorf("no access token in response: %s", string(body))
 	}
 
 	return token, nil
 }
 
+// expiresAt converts a token response's expires_in (seconds) to a time.
+// Tokens without expires_in don't expire.
+func expiresAt(resp map[string]interface{}) time.Time {
+	if expiresIn, ok := resp["expires_in"].(float64); ok && expiresIn > 0 {
+		return time.Now().Add(time.Duration(expiresIn) * time.Second)
+	}
+	return time.Time{}
+}
+
 func shutdownServer(server *http.Server) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+//go:embed templates/*.html
+var defaultTemplates embed.FS
+
+const (
+	successTemplate = "success.html"
+	errorTemplate   = "error.html"
+)
+
+// pageData is passed to the callback page templates
+type pageData struct {
+	Service          string // Service display name
+	Account          string // Connected user, if known
+	Workspace        string // Connected workspace/team, if known
+	Error            string // Error code or title (error page only)
+	ErrorDescription string // Error details (error page only)
+}
+
+// callbackPages renders the pages shown in the browser after the OAuth
+// redirect. Values are escaped by html/template.
+type callbackPages struct {
+	success *template.Template
+	error   *template.Template
+}
+
+// loadCallbackPages parses the page templates. Templates in dir (if it
+// exists) replace the built-in ones, one file at a time.
+func loadCallbackPages(dir string) (*callbackPages, error) {
+	success, err := loadPageTemplate(dir, successTemplate)
+	if err != nil {
+		return nil, err
+	}
+	errPage, err := loadPageTemplate(dir, errorTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return &callbackPages{success: success, error: errPage}, nil
+}
+
+func loadPageTemplate(dir, name string) (*template.Template, error) {
+	if dir != "" {
+		path := filepath.Join(dir, name)
+		data, err := os.ReadFile(path)
+		if err == nil {
+			tmpl, err := template.New(name).Parse(string(data))
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse template %s: %w", path, err)
+			}
+			return tmpl, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read template %s: %w", path, err)
+		}
+	}
+
+	return template.ParseFS(defaultTemplates, "templates/"+name)
+}
+
+func (p *callbackPages) writeSuccess(w http.ResponseWriter, data pageData) {
+	writePage(w, http.StatusOK, p.success, data)
+}
+
+func (p *callbackPages) writeError(w http.ResponseWriter, status int, data pageData) {
+	writePage(w, status, p.error, data)
+}
+
+func writePage(w http.ResponseWriter, status int, tmpl *template.Template, data pageData) {
+	// Render first so a template error doesn't leave a half-written page
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		http.Error(w, fmt.Sprintf("failed to render page: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,143 @@
+package bundle
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jaknapp/applink/internal/config"
+	"github.com/jaknapp/applink/internal/storage"
+)
+
+const (
+	format  = "applink-bundle"
+	version = 1
+
+	// iterations is the PBKDF2-SHA256 work factor (OWASP's 2023 guidance)
+	iterations = 600000
+	saltSize   = 16
+)
+
+// Bundle is the decrypted content of a bundle file: what `applink export`
+// moves to another machine
+type Bundle struct {
+	CreatedAt time.Time `json:"created_at"`
+	Services  []Service `json:"services"`
+}
+
+// Service holds everything stored for one service. Each part is nil if
+// there was nothing to export.
+type Service struct {
+	ID          string                  `json:"id"`
+	Credentials *storage.Credentials    `json:"credentials,omitempty"`
+	Token       *storage.Token          `json:"token,omitempty"`
+	Settings    *config.ServiceSettings `json:"settings,omitempty"`
+}
+
+// envelope is the on-disk format: the encrypted bundle and the parameters
+// needed to decrypt it
+type envelope struct {
+	Format     string `json:"format"`
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Cipher     string `json:"cipher"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// Encrypt serializes a bundle and encrypts it with AES-256-GCM under a key
+// derived from passphrase with PBKDF2-SHA256
+func Encrypt(b *Bundle, passphrase string) ([]byte, error) {
+	plaintext, err := json.Marshal(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize bundle: %w", err)
+	}
+
+	env := envelope{
+		Format:     format,
+		Version:    version,
+		KDF:        "pbkdf2-sha256",
+		Iterations: iterations,
+		Salt:       make([]byte, saltSize),
+		Cipher:     "aes-256-gcm",
+	}
+	if _, err := rand.Read(env.Salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	gcm, err := newGCM(passphrase, env.Salt, env.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	env.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(env.Nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	// The header is authenticated, so its parameters can't be swapped
+	env.Ciphertext = gcm.Seal(nil, env.Nonce, plaintext, header(&env))
+
+	return json.MarshalIndent(&env, "", "  ")
+}
+
+// Decrypt decrypts and parses a bundle file
+func Decrypt(data []byte, passphrase string) (*Bundle, error) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil || env.Format != format {
+		return nil, fmt.Errorf("not an applink bundle")
+	}
+	if env.Version != version || env.KDF != "pbkdf2-sha256" || env.Cipher != "aes-256-gcm" {
+		return nil, fmt.Errorf("unsupported bundle version %d (%s, %s); upgrade applink", env.Version, env.KDF, env.Cipher)
+	}
+	// The work factor comes from the file; anything other than what Encrypt
+	// writes could make key derivation take forever
+	if env.Iterations != iterations || len(env.Salt) != saltSize {
+		return nil, fmt.Errorf("bundle is corrupt: bad key derivation parameters")
+	}
+
+	gcm, err := newGCM(passphrase, env.Salt, env.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("bundle is corrupt: bad nonce")
+	}
+
+	plaintext, err := gcm.Open(nil, env.Nonce, env.Ciphertext, header(&env))
+	if err != nil {
+		return nil, fmt.Errorf("wrong passphrase, or the bundle is corrupt")
+	}
+
+	var b Bundle
+	if err := json.Unmarshal(plaintext, &b); err != nil {
+		return nil, fmt.Errorf("failed to parse bundle: %w", err)
+	}
+	return &b, nil
+}
+
+// header is the additional authenticated data: the envelope's parameters
+func header(env *envelope) []byte {
+	return []byte(fmt.Sprintf("%s/%d/%s/%d/%s", env.Format, env.Version, env.KDF, env.Iterations, env.Cipher))
+}
+
+func newGCM(passphrase string, salt []byte, iter int) (cipher.AEAD, error) {
+	if iter < 1 || len(salt) == 0 {
+		return nil, fmt.Errorf("bundle is corrupt: bad key derivation parameters")
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iter, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
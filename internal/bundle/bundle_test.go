@@ -0,0 +1,147 @@
+package bundle
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaknapp/applink/internal/config"
+	"github.com/jaknapp/applink/internal/storage"
+)
+
+const testPassphrase = "correct horse battery staple"
+
+func testBundle() *Bundle {
+	return &Bundle{
+		CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Services: []Service{
+			{
+				ID:          "slack",
+				Credentials: &storage.Credentials{ClientID: "id", ClientSecret: "secret"},
+				Token: &storage.Token{
+					AccessToken:  "xoxp-token",
+					RefreshToken: "refresh",
+					ExpiresAt:    time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+				},
+				Settings: &config.ServiceSettings{CallbackPorts: []int{8443, 8444}},
+			},
+			{ID: "linear", Token: &storage.Token{AccessToken: "lin_token"}},
+		},
+	}
+}
+
+func encryptTestBundle(t *testing.T) []byte {
+	t.Helper()
+	data, err := Encrypt(testBundle(), testPassphrase)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	return data
+}
+
+// modifyEnvelope decodes a bundle file, applies change and re-encodes it
+func modifyEnvelope(t *testing.T, data []byte, change func(env *envelope)) []byte {
+	t.Helper()
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		t.Fatal(err)
+	}
+	change(&env)
+	out, err := json.Marshal(&env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	data := encryptTestBundle(t)
+
+	if strings.Contains(string(data), "xoxp-token") || strings.Contains(string(data), "secret") {
+		t.Fatal("bundle file contains plaintext secrets")
+	}
+
+	got, err := Decrypt(data, testPassphrase)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	if want := testBundle(); !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip mismatch:\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestEncryptUsesFreshSaltAndNonce(t *testing.T) {
+	if string(encryptTestBundle(t)) == string(encryptTestBundle(t)) {
+		t.Error("two encryptions of the same bundle are identical")
+	}
+}
+
+func TestDecryptWrongPassphrase(t *testing.T) {
+	_, err := Decrypt(encryptTestBundle(t), "wrong passphrase")
+	if err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Errorf("got error %v, want wrong passphrase", err)
+	}
+}
+
+func TestDecryptRejectsTampering(t *testing.T) {
+	data := encryptTestBundle(t)
+
+	tests := []struct {
+		name   string
+		change func(env *envelope)
+		want   string
+	}{
+		{"format", func(env *envelope) { env.Format = "other" }, "not an applink bundle"},
+		{"version", func(env *envelope) { env.Version = 2 }, "unsupported bundle version"},
+		{"kdf", func(env *envelope) { env.KDF = "scrypt" }, "unsupported bundle version"},
+		{"cipher", func(env *envelope) { env.Cipher = "aes-128-gcm" }, "unsupported bundle version"},
+		{"huge iterations", func(env *envelope) { env.Iterations = 2147483647 }, "bad key derivation parameters"},
+		{"low iterations", func(env *envelope) { env.Iterations = 1 }, "bad key derivation parameters"},
+		{"missing salt", func(env *envelope) { env.Salt = nil }, "bad key derivation parameters"},
+		{"salt", func(env *envelope) { env.Salt[0] ^= 1 }, "wrong passphrase, or the bundle is corrupt"},
+		{"nonce", func(env *envelope) { env.Nonce[0] ^= 1 }, "wrong passphrase, or the bundle is corrupt"},
+		{"short nonce", func(env *envelope) { env.Nonce = env.Nonce[:4] }, "bad nonce"},
+		{"ciphertext", func(env *envelope) { env.Ciphertext[0] ^= 1 }, "wrong passphrase, or the bundle is corrupt"},
+		{"truncated ciphertext", func(env *envelope) { env.Ciphertext = env.Ciphertext[:len(env.Ciphertext)-1] }, "wrong passphrase, or the bundle is corrupt"},
+		{"empty ciphertext", func(env *envelope) { env.Ciphertext = nil }, "wrong passphrase, or the bundle is corrupt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decrypt(modifyEnvelope(t, data, tt.change), testPassphrase)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeaderIsAuthenticated(t *testing.T) {
+	var env envelope
+	if err := json.Unmarshal(encryptTestBundle(t), &env); err != nil {
+		t.Fatal(err)
+	}
+
+	gcm, err := newGCM(testPassphrase, env.Salt, env.Iterations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gcm.Open(nil, env.Nonce, env.Ciphertext, header(&env)); err != nil {
+		t.Fatalf("Open with the original header: %v", err)
+	}
+
+	env.Iterations++
+	if _, err := gcm.Open(nil, env.Nonce, env.Ciphertext, header(&env)); err == nil {
+		t.Error("Open succeeded with a modified header")
+	}
+}
+
+func TestDecryptNotABundle(t *testing.T) {
+	for _, data := range []string{"", "not json", `{"format": "something-else"}`} {
+		if _, err := Decrypt([]byte(data), testPassphrase); err == nil || !strings.Contains(err.Error(), "not an applink bundle") {
+			t.Errorf("Decrypt(%q): got error %v, want not an applink bundle", data, err)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jaknapp/applink/internal/api"
+)
+
+const cassetteVersion = 1
+
+const redacted = "[REDACTED]"
+
+// Cassette is a file of recorded HTTP interactions
+type Cassette struct {
+	Version      int            `json:"version"`
+	Interactions []*Interaction `json:"interactions"`
+
+	path string
+	mu   sync.Mutex
+	used map[*Interaction]bool
+}
+
+// Interaction is a recorded request/response pair
+type Interaction struct {
+	Request    Request   `json:"request"`
+	Response   Response  `json:"response"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// Request is the recorded part of an HTTP request
+type Request struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+// Response is the recorded part of an HTTP response
+type Response struct {
+	StatusCode int         `json:"status_code"`
+	Headers    http.Header `json:"headers,omitempty"`
+	Body       string      `json:"body"`
+}
+
+// Load reads a cassette file. A missing file yields an empty cassette.
+func Load(path string) (*Cassette, error) {
+	c := &Cassette{Version: cassetteVersion, path: path, used: make(map[*Interaction]bool)}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+	}
+	if c.Version != cassetteVersion {
+		return nil, fmt.Errorf("unsupported cassette version %d in %s", c.Version, path)
+	}
+
+	return c, nil
+}
+
+// Save writes the cassette back to its file
+func (c *Cassette) Save() error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, 0600)
+}
+
+// find returns the first unused interaction matching the request. Once all
+// matches have been served, the last one is replayed again.
+func (c *Cassette) find(method string, u *url.URL, body []byte) *Interaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var last *Interaction
+	for _, in := range c.Interactions {
+		if !in.matches(method, u, body) {
+			continue
+		}
+		if !c.used[in] {
+			c.used[in] = true
+			return in
+		}
+		last = in
+	}
+	return last
+}
+
+func (in *Interaction) matches(method string, u *url.URL, body []byte) bool {
+	if in.Request.Method != method {
+		return false
+	}
+
+	recorded, err := url.Parse(in.Request.URL)
+	if err != nil {
+		return false
+	}
+	if recorded.Path != u.Path || normalizeQuery(recorded) != normalizeQuery(u) {
+		return false
+	}
+
+	return normalizeBody([]byte(in.Request.Body)) == normalizeBody(body)
+}
+
+// normalizeQuery sorts query parameters so their order doesn't matter
+func normalizeQuery(u *url.URL) string {
+	return u.Query().Encode()
+}
+
+// normalizeBody compacts JSON bodies so formatting doesn't matter
+func normalizeBody(body []byte) string {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, body); err == nil {
+		return compact.String()
+	}
+	return string(body)
+}
+
+// readBody drains a request body and puts back an identical reader
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+func redactHeaders(h http.Header) http.Header {
+	out := h.Clone()
+	for _, name := range api.SensitiveHeaders {
+		if out.Get(name) != "" {
+			out.Set(name, redacted)
+		}
+	}
+	return out
+}
@@ -0,0 +1,101 @@
+package cassette
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Recorder is an http.RoundTripper that sends requests through Base and
+// appends each request/response pair to the cassette
+type Recorder struct {
+	Cassette *Cassette
+	Base     http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	base := r.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	c := r.Cassette
+	c.mu.Lock()
+	c.Interactions = append(c.Interactions, &Interaction{
+		Request: Request{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Headers: redactHeaders(req.Header),
+			Body:    string(reqBody),
+		},
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Headers:    redactHeaders(resp.Header),
+			Body:       string(respBody),
+		},
+		RecordedAt: time.Now().UTC(),
+	})
+	err = c.Save()
+	c.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to save cassette: %w", err)
+	}
+
+	return resp, nil
+}
+
+// Player is an http.RoundTripper that answers requests from a cassette
+// without touching the network
+type Player struct {
+	Cassette *Cassette
+}
+
+// RoundTrip implements http.RoundTripper
+func (p *Player) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	in := p.Cassette.find(req.Method, req.URL, body)
+	if in == nil {
+		return nil, fmt.Errorf("no recorded response for %s %s in %s", req.Method, req.URL.RequestURI(), p.Cassette.path)
+	}
+
+	header := in.Response.Headers.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", in.Response.StatusCode, http.StatusText(in.Response.StatusCode)),
+		StatusCode:    in.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(in.Response.Body))),
+		ContentLength: int64(len(in.Response.Body)),
+		Request:       req,
+	}, nil
+}
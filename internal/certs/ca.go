@@ -10,6 +10,7 @@ import (
 	"encoding/pem"
 	"fmt"
 	"math/big"
+	"net"
 	"os"
 	"path/filepath"
 	"time"
@@ -20,6 +21,20 @@ const (
 	caCertFile = "applink-ca.pem"
 )
 
+// caValidity is how long a new CA is valid. The CA only needs to outlive
+// the leaf certificates it signs; rotate it with `applink certs rotate`.
+const caValidity = 2 * 365 * 24 * time.Hour
+
+// Name constraints limit the CA to loopback names, so even a stolen CA key
+// can't mint certificates that browsers accept for other sites
+var (
+	permittedDNSDomains = []string{"localhost"}
+	permittedIPRanges   = []*net.IPNet{
+		{IP: net.IPv4(127, 0, 0, 1).To4(), Mask: net.CIDRMask(32, 32)},
+		{IP: net.IPv6loopback, Mask: net.CIDRMask(128, 128)},
+	}
+)
+
 // GetCertsDir returns the directory where certificates are stored
 func GetCertsDir() (string, error) {
 	homeDir, err := os.UserHomeDir()
@@ -77,12 +92,16 @@ func GenerateCA() error {
 			CommonName:         "applink Local CA",
 		},
 		NotBefore:             time.Now(),
-		NotAfter:              time.Now().AddDate(10, 0, 0), // Valid for 10 years
+		NotAfter:              time.Now().Add(caValidity),
 		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
 		BasicConstraintsValid: true,
 		IsCA:                  true,
 		MaxPathLen:            0,
 		MaxPathLenZero:        true,
+
+		PermittedDNSDomainsCritical: true,
+		PermittedDNSDomains:         permittedDNSDomains,
+		PermittedIPRanges:           permittedIPRanges,
 	}
 
 	// Self-sign the CA certificate
@@ -91,20 +110,9 @@ func GenerateCA() error {
 		return fmt.Errorf("failed to create CA certificate: %w", err)
 	}
 
-	// Save CA private key
-	keyPath := filepath.Join(certsDir, caKeyFile)
-	keyFile, err := os.OpenFile(keyPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
-	if err != nil {
-		return fmt.Errorf("failed to create CA key file: %w", err)
-	}
-	defer keyFile.Close()
-
-	keyDER, err := x509.MarshalECPrivateKey(caKey)
-	if err != nil {
-		return fmt.Errorf("failed to marshal CA private key: %w", err)
-	}
-	if err := pem.Encode(keyFile, &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}); err != nil {
-		return fmt.Errorf("failed to write CA private key: %w", err)
+	// Save CA private key (see SetKeyStorage)
+	if err := writeCAKey(filepath.Join(certsDir, caKeyFile), caKey); err != nil {
+		return err
 	}
 
 	// Save CA certificate
@@ -131,7 +139,8 @@ func GetCACertPath() (string, error) {
 	return filepath.Join(certsDir, caCertFile), nil
 }
 
-// LoadCA loads the CA certificate and private key
+// LoadCA loads the CA certificate and private key. The key may be a plain
+// PEM file, encrypted with a key from the keychain, or kept in the keychain.
 func LoadCA() (*x509.Certificate, *ecdsa.PrivateKey, error) {
 	certsDir, err := GetCertsDir()
 	if err != nil {
@@ -145,14 +154,9 @@ func LoadCA() (*x509.Certificate, *ecdsa.PrivateKey, error) {
 		return nil, nil, fmt.Errorf("failed to read CA private key: %w", err)
 	}
 
-	keyBlock, _ := pem.Decode(keyPEM)
-	if keyBlock == nil {
-		return nil, nil, fmt.Errorf("failed to decode CA private key PEM")
-	}
-
-	caKey, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+	caKey, err := readCAKey(keyPEM)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to parse CA private key: %w", err)
+		return nil, nil, err
 	}
 
 	// Load CA certificate
@@ -175,24 +179,41 @@ func LoadCA() (*x509.Certificate, *ecdsa.PrivateKey, error) {
 	return caCert, caKey, nil
 }
 
-// GenerateServerCert creates a TLS certificate for localhost signed by the CA
+// GenerateServerCert creates a TLS certificate for localhost signed by the
+// CA. ServerCert reuses a cached one instead.
 func GenerateServerCert() (tls.Certificate, error) {
+	certPEM, keyPEM, err := generateServerCertPEM()
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// generateServerCertPEM signs a new localhost certificate and returns it
+// and its private key in PEM format
+func generateServerCertPEM() ([]byte, []byte, error) {
 	// Load CA
 	caCert, caKey, err := LoadCA()
 	if err != nil {
-		return tls.Certificate{}, fmt.Errorf("failed to load CA: %w", err)
+		return nil, nil, fmt.Errorf("failed to load CA: %w", err)
 	}
 
 	// Generate server private key
 	serverKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	if err != nil {
-		return tls.Certificate{}, fmt.Errorf("failed to generate server private key: %w", err)
+		return nil, nil, fmt.Errorf("failed to generate server private key: %w", err)
 	}
 
 	// Create server certificate template
 	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
 	if err != nil {
-		return tls.Certificate{}, fmt.Errorf("failed to generate serial number: %w", err)
+		return nil, nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	// A leaf must not outlive the CA that signed it
+	notAfter := time.Now().AddDate(1, 0, 0)
+	if notAfter.After(caCert.NotAfter) {
+		notAfter = caCert.NotAfter
 	}
 
 	serverTemplate := x509.Certificate{
@@ -202,17 +223,18 @@ func GenerateServerCert() (tls.Certificate, error) {
 			CommonName:   "localhost",
 		},
 		NotBefore:             time.Now(),
-		NotAfter:              time.Now().AddDate(1, 0, 0), // Valid for 1 year
+		NotAfter:              notAfter, // Valid for 1 year, or until the CA expires
 		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
 		BasicConstraintsValid: true,
 		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
 	}
 
 	// Sign server certificate with CA
 	serverCertDER, err := x509.CreateCertificate(rand.Reader, &serverTemplate, caCert, &serverKey.PublicKey, caKey)
 	if err != nil {
-		return tls.Certificate{}, fmt.Errorf("failed to create server certificate: %w", err)
+		return nil, nil, fmt.Errorf("failed to create server certificate: %w", err)
 	}
 
 	// Encode to PEM
@@ -220,10 +242,9 @@ func GenerateServerCert() (tls.Certificate, error) {
 
 	serverKeyDER, err := x509.MarshalECPrivateKey(serverKey)
 	if err != nil {
-		return tls.Certificate{}, fmt.Errorf("failed to marshal server private key: %w", err)
+		return nil, nil, fmt.Errorf("failed to marshal server private key: %w", err)
 	}
 	serverKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: serverKeyDER})
 
-	// Create TLS certificate
-	return tls.X509KeyPair(serverCertPEM, serverKeyPEM)
+	return serverCertPEM, serverKeyPEM, nil
 }
@@ -0,0 +1,336 @@
+package certs
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jaknapp/applink/internal/storage"
+)
+
+// Where the CA private key is kept. In every mode applink-ca-key.pem
+// exists; its PEM block type says where the key really is.
+const (
+	// KeyStorageAuto uses KeyStorageEncrypted if the keychain is
+	// available, and KeyStorageFile otherwise
+	KeyStorageAuto = "auto"
+	// KeyStorageKeyring keeps the key in the keychain; the file only
+	// refers to it
+	KeyStorageKeyring = "keyring"
+	// KeyStorageEncrypted encrypts the file with a key held in the keychain
+	KeyStorageEncrypted = "encrypted"
+	// KeyStorageFile writes the key as a plain PEM file (mode 0600)
+	KeyStorageFile = "file"
+)
+
+// PEM block types of applink-ca-key.pem
+const (
+	pemTypePlainKey     = "EC PRIVATE KEY"
+	pemTypeKeyReference = "APPLINK CA KEY REFERENCE"
+	pemTypeEncryptedKey = "APPLINK ENCRYPTED CA KEY"
+)
+
+// Prefixes of the keychain items holding CA key material; each CA gets
+// its own items (see newItemName)
+const (
+	secretCAKey           = "ca-key"
+	secretCAEncryptionKey = "ca-key-encryption-key"
+)
+
+// keyStorage is the mode used when a new CA is generated
+var keyStorage = KeyStorageAuto
+
+// SetKeyStorage sets where GenerateCA keeps the CA private key
+func SetKeyStorage(mode string) error {
+	switch mode {
+	case "":
+		keyStorage = KeyStorageAuto
+	case KeyStorageAuto, KeyStorageKeyring, KeyStorageEncrypted, KeyStorageFile:
+		keyStorage = mode
+	default:
+		return fmt.Errorf("unknown CA key storage %q (expected auto, keyring, encrypted or file)", mode)
+	}
+	return nil
+}
+
+// CAKeyStorage reports where the current CA private key is kept
+func CAKeyStorage() (string, error) {
+	certsDir, err := GetCertsDir()
+	if err != nil {
+		return "", err
+	}
+
+	keyPEM, err := os.ReadFile(filepath.Join(certsDir, caKeyFile))
+	if err != nil {
+		return "", fmt.Errorf("failed to read CA private key: %w", err)
+	}
+
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return "", fmt.Errorf("failed to decode CA private key PEM")
+	}
+
+	switch block.Type {
+	case pemTypeKeyReference:
+		return KeyStorageKeyring, nil
+	case pemTypeEncryptedKey:
+		return KeyStorageEncrypted, nil
+	default:
+		return KeyStorageFile, nil
+	}
+}
+
+// writeCAKey stores the CA private key according to keyStorage. The new
+// key goes under fresh keychain item names and the previous CA's items are
+// only removed once the new file is in place, so a failure leaves the old
+// CA loadable.
+func writeCAKey(path string, key *ecdsa.PrivateKey) error {
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to marshal CA private key: %w", err)
+	}
+
+	oldItem := keyFileItem(path)
+
+	var block *pem.Block
+	switch keyStorage {
+	case KeyStorageKeyring:
+		block, err = keyringKeyBlock(keyDER)
+	case KeyStorageEncrypted:
+		block, err = encryptedKeyBlock(keyDER)
+	case KeyStorageFile:
+		block = &pem.Block{Type: pemTypePlainKey, Bytes: keyDER}
+	default:
+		// Headless systems often have no keychain; fall back to the file
+		block, err = encryptedKeyBlock(keyDER)
+		if err != nil && storage.IsKeychainError(err) {
+			block, err = &pem.Block{Type: pemTypePlainKey, Bytes: keyDER}, nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := writeKeyFile(path, pem.EncodeToMemory(block)); err != nil {
+		if item := keychainItem(block); item != "" {
+			storage.DeleteSecret(item)
+		}
+		return err
+	}
+
+	// Drop key material left by the previous CA
+	if oldItem != "" {
+		storage.DeleteSecret(oldItem)
+	}
+	return nil
+}
+
+// writeKeyFile replaces path through a temporary file so that the old key
+// survives a failed write
+func writeKeyFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), caKeyFile+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create CA key file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to create CA key file: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write CA private key: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write CA private key: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write CA private key: %w", err)
+	}
+	return nil
+}
+
+// newItemName returns a keychain item name not used by an earlier CA
+func newItemName(prefix string) (string, error) {
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate keychain item name: %w", err)
+	}
+	return prefix + "-" + hex.EncodeToString(suffix), nil
+}
+
+// keychainItem returns the keychain item a key block refers to, or "" for
+// a plain key. Files without a Keychain-Item header use the fixed names of
+// earlier versions.
+func keychainItem(block *pem.Block) string {
+	item := block.Headers["Keychain-Item"]
+	switch {
+	case item != "":
+		return item
+	case block.Type == pemTypeKeyReference:
+		return secretCAKey
+	case block.Type == pemTypeEncryptedKey:
+		return secretCAEncryptionKey
+	}
+	return ""
+}
+
+// keyFileItem returns the keychain item the key file at path refers to,
+// or "" if there is none
+func keyFileItem(path string) string {
+	keyPEM, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return ""
+	}
+	return keychainItem(block)
+}
+
+// keyringKeyBlock stores the key in the keychain and returns the block
+// that refers to it
+func keyringKeyBlock(keyDER []byte) (*pem.Block, error) {
+	item, err := newItemName(secretCAKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := storage.StoreSecret(item, base64.StdEncoding.EncodeToString(keyDER)); err != nil {
+		return nil, fmt.Errorf("failed to store CA private key in keychain: %w", err)
+	}
+	return &pem.Block{
+		Type:    pemTypeKeyReference,
+		Headers: map[string]string{"Keychain-Item": item},
+	}, nil
+}
+
+// encryptedKeyBlock encrypts the key with AES-256-GCM under a new key
+// held in the keychain
+func encryptedKeyBlock(keyDER []byte) (*pem.Block, error) {
+	encKey := make([]byte, 32)
+	if _, err := rand.Read(encKey); err != nil {
+		return nil, fmt.Errorf("failed to generate encryption key: %w", err)
+	}
+
+	gcm, err := newGCM(encKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	item, err := newItemName(secretCAEncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := storage.StoreSecret(item, base64.StdEncoding.EncodeToString(encKey)); err != nil {
+		return nil, fmt.Errorf("failed to store CA key encryption key in keychain: %w", err)
+	}
+
+	return &pem.Block{
+		Type: pemTypeEncryptedKey,
+		Headers: map[string]string{
+			"Cipher":        "AES-256-GCM",
+			"Keychain-Item": item,
+		},
+		Bytes: gcm.Seal(nonce, nonce, keyDER, nil),
+	}, nil
+}
+
+// readCAKey decodes applink-ca-key.pem in any of the storage formats
+func readCAKey(keyPEM []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode CA private key PEM")
+	}
+
+	var keyDER []byte
+	switch block.Type {
+	case pemTypeKeyReference:
+		encoded, err := storage.GetSecret(keychainItem(block))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA private key from keychain: %w", err)
+		}
+		if encoded == "" {
+			return nil, fmt.Errorf("CA private key is missing from the keychain; run: applink certs rotate")
+		}
+		if keyDER, err = base64.StdEncoding.DecodeString(encoded); err != nil {
+			return nil, fmt.Errorf("failed to decode CA private key: %w", err)
+		}
+	case pemTypeEncryptedKey:
+		var err error
+		if keyDER, err = decryptKeyBlock(block); err != nil {
+			return nil, err
+		}
+	default:
+		// Plain file, as written by older versions
+		keyDER = block.Bytes
+	}
+
+	caKey, err := x509.ParseECPrivateKey(keyDER)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA private key: %w", err)
+	}
+	return caKey, nil
+}
+
+func decryptKeyBlock(block *pem.Block) ([]byte, error) {
+	encoded, err := storage.GetSecret(keychainItem(block))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA key encryption key from keychain: %w", err)
+	}
+	if encoded == "" {
+		return nil, fmt.Errorf("CA key encryption key is missing from the keychain; run: applink certs rotate")
+	}
+	encKey, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode CA key encryption key: %w", err)
+	}
+
+	gcm, err := newGCM(encKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(block.Bytes) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted CA private key is truncated")
+	}
+	nonce, ciphertext := block.Bytes[:gcm.NonceSize()], block.Bytes[gcm.NonceSize():]
+
+	keyDER, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt CA private key: %w", err)
+	}
+	return keyDER, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// deleteCAKeySecrets removes the current CA's key material from the
+// keychain. It must run before the key file is removed.
+func deleteCAKeySecrets() {
+	certsDir, err := GetCertsDir()
+	if err != nil {
+		return
+	}
+	if item := keyFileItem(filepath.Join(certsDir, caKeyFile)); item != "" {
+		storage.DeleteSecret(item)
+	}
+}
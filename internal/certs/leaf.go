@@ -0,0 +1,82 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	serverCertFile = "applink-localhost.pem"
+	serverKeyFile  = "applink-localhost-key.pem"
+)
+
+// serverCertRenewal is how long before expiry a cached server certificate
+// is replaced
+const serverCertRenewal = 30 * 24 * time.Hour
+
+// ServerCert returns the TLS certificate for the localhost callback server.
+// The certificate is cached in the certs directory and reused until it is
+// about to expire or no longer chains to the current CA (e.g. after a
+// rotation), so the CA private key is only needed to sign a new one.
+func ServerCert() (tls.Certificate, error) {
+	certsDir, err := GetCertsDir()
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	certPath := filepath.Join(certsDir, serverCertFile)
+	keyPath := filepath.Join(certsDir, serverKeyFile)
+
+	if cert, err := loadCachedServerCert(certPath, keyPath); err == nil {
+		return cert, nil
+	}
+
+	certPEM, keyPEM, err := generateServerCertPEM()
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	// A cache that can't be written only costs a new certificate next time
+	if err := os.WriteFile(keyPath, keyPEM, 0600); err == nil {
+		os.WriteFile(certPath, certPEM, 0644)
+	}
+
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// loadCachedServerCert loads the cached server certificate and checks that
+// it is still usable
+func loadCachedServerCert(certPath, keyPath string) (tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if time.Until(leaf.NotAfter) < serverCertRenewal {
+		return tls.Certificate{}, fmt.Errorf("server certificate expires on %s", leaf.NotAfter.Format("2006-01-02"))
+	}
+
+	caCert, err := LoadCACert()
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert)
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		DNSName:   "localhost",
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}); err != nil {
+		return tls.Certificate{}, fmt.Errorf("server certificate is not signed by the current CA: %w", err)
+	}
+
+	cert.Leaf = leaf
+	return cert, nil
+}
@@ -0,0 +1,104 @@
+package certs
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Fingerprint returns the SHA-256 fingerprint of a certificate as
+// colon-separated hex, the way browsers show it
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// thumbprint returns the SHA-1 hash that the macOS and Windows certificate
+// tools use to identify a certificate
+func thumbprint(cert *x509.Certificate) string {
+	sum := sha1.Sum(cert.Raw)
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// IsConstrained reports whether a CA certificate carries name constraints.
+// CAs created by older versions of applink are unconstrained and can sign
+// certificates for any domain.
+func IsConstrained(cert *x509.Certificate) bool {
+	return len(cert.PermittedDNSDomains) > 0 || len(cert.PermittedIPRanges) > 0
+}
+
+// LoadCACert loads the CA certificate without its private key
+func LoadCACert() (*x509.Certificate, error) {
+	certPath, err := GetCACertPath()
+	if err != nil {
+		return nil, err
+	}
+
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+	}
+
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode CA certificate PEM")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+	}
+
+	return cert, nil
+}
+
+// RotateCA replaces the CA with a new one: the new CA is generated and
+// installed first, then the old one is removed from the trust store by
+// fingerprint. It returns the old certificate if it was removed (nil if
+// there was none or it wasn't trusted) and the warnings from InstallCA.
+func RotateCA() (removed *x509.Certificate, warnings []error, err error) {
+	old, err := LoadCACert()
+	if err != nil {
+		old = nil // No usable CA to replace
+	}
+
+	if err := GenerateCA(); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate CA: %w", err)
+	}
+
+	warnings, err = InstallCA()
+	if err != nil {
+		return nil, warnings, fmt.Errorf("new CA generated but not installed: %w", err)
+	}
+
+	if old == nil || !IsCertInstalled(old) {
+		return nil, warnings, nil
+	}
+	if err := UninstallCert(old); err != nil {
+		return nil, warnings, fmt.Errorf("new CA installed, but the old one could not be removed: %w", err)
+	}
+	return old, warnings, nil
+}
+
+// RemoveCAFiles deletes the certificates directory (~/.applink/certs) and
+// any CA key material in the keychain
+func RemoveCAFiles() error {
+	certsDir, err := GetCertsDir()
+	if err != nil {
+		return err
+	}
+	deleteCAKeySecrets()
+	if err := os.RemoveAll(certsDir); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", certsDir, err)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package certs
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// nssDB is an NSS certificate database, as used by Firefox and Chromium
+// on Linux
+type nssDB struct {
+	name string // e.g. "Chromium" or "Firefox (default-release)"
+	dir  string
+}
+
+// spec returns the database argument for certutil -d
+func (db nssDB) spec() string {
+	// cert9.db is the SQL format; older Firefox profiles only have cert8.db
+	if _, err := os.Stat(filepath.Join(db.dir, "cert9.db")); err == nil {
+		return "sql:" + db.dir
+	}
+	return "dbm:" + db.dir
+}
+
+// findNSSDBs returns the NSS databases in the user's home directory:
+// Chromium's shared ~/.pki/nssdb and every Firefox profile (including the
+// Snap and Flatpak packages)
+func findNSSDBs() []nssDB {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+
+	var dbs []nssDB
+	if hasNSSFiles(filepath.Join(home, ".pki", "nssdb")) {
+		dbs = append(dbs, nssDB{name: "Chromium", dir: filepath.Join(home, ".pki", "nssdb")})
+	}
+
+	profileRoots := []string{
+		filepath.Join(home, ".mozilla", "firefox"),
+		filepath.Join(home, "snap", "firefox", "common", ".mozilla", "firefox"),
+		filepath.Join(home, ".var", "app", "org.mozilla.firefox", ".mozilla", "firefox"),
+	}
+	for _, root := range profileRoots {
+		profiles, _ := filepath.Glob(filepath.Join(root, "*"))
+		for _, dir := range profiles {
+			if !hasNSSFiles(dir) {
+				continue
+			}
+			// Profile directories are named "<salt>.<profile name>"
+			profile := filepath.Base(dir)
+			if i := strings.Index(profile, "."); i >= 0 {
+				profile = profile[i+1:]
+			}
+			dbs = append(dbs, nssDB{name: "Firefox (" + profile + ")", dir: dir})
+		}
+	}
+
+	return dbs
+}
+
+func hasNSSFiles(dir string) bool {
+	for _, file := range []string{"cert9.db", "cert8.db"} {
+		if _, err := os.Stat(filepath.Join(dir, file)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// nssNickname is the name a certificate is stored under. It includes the
+// thumbprint so an old and a new CA never share a nickname.
+func nssNickname(cert *x509.Certificate) string {
+	return cert.Subject.CommonName + " " + thumbprint(cert)[:16]
+}
+
+// errNoCertutil is reported for NSS databases when certutil is missing
+var errNoCertutil = fmt.Errorf("certutil not found (install libnss3-tools or nss-tools)")
+
+// installCANSS adds the CA to every NSS database. Nothing is done if
+// there are none, unless they are the only trust store in use.
+func installCANSS(certPath string) error {
+	dbs := findNSSDBs()
+	if len(dbs) == 0 {
+		if trustStore == TrustStoreUser {
+			return fmt.Errorf("no Firefox or Chromium certificate databases found; start the browser once, or use --trust-store system")
+		}
+		return nil
+	}
+	if _, err := exec.LookPath("certutil"); err != nil {
+		return errNoCertutil
+	}
+
+	cert, err := LoadCACert()
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, db := range dbs {
+		if nssHoldsCert(db, cert) {
+			continue
+		}
+		cmd := exec.Command("certutil", "-d", db.spec(), "-A", "-t", "C,,", "-n", nssNickname(cert), "-i", certPath)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v: %s", db.name, err, strings.TrimSpace(string(output))))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to add CA to NSS database(s):\n%s", strings.Join(failed, "\n"))
+	}
+	return nil
+}
+
+// uninstallCertNSS removes the certificate from every NSS database
+func uninstallCertNSS(cert *x509.Certificate) error {
+	if _, err := exec.LookPath("certutil"); err != nil {
+		return nil
+	}
+
+	var failed []string
+	for _, db := range findNSSDBs() {
+		if !nssHoldsCert(db, cert) {
+			continue
+		}
+		cmd := exec.Command("certutil", "-d", db.spec(), "-D", "-n", nssNickname(cert))
+		if output, err := cmd.CombinedOutput(); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v: %s", db.name, err, strings.TrimSpace(string(output))))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to remove CA from NSS database(s):\n%s", strings.Join(failed, "\n"))
+	}
+	return nil
+}
+
+// nssHoldsCert reports whether the database holds this exact certificate
+func nssHoldsCert(db nssDB, cert *x509.Certificate) bool {
+	output, err := exec.Command("certutil", "-d", db.spec(), "-L", "-n", nssNickname(cert), "-a").Output()
+	if err != nil {
+		return false
+	}
+	for {
+		var block *pem.Block
+		block, output = pem.Decode(output)
+		if block == nil {
+			return false
+		}
+		if bytes.Equal(block.Bytes, cert.Raw) {
+			return true
+		}
+	}
+}
+
+// nssStatus reports each NSS database and whether it holds cert
+func nssStatus(cert *x509.Certificate) []StoreStatus {
+	_, lookErr := exec.LookPath("certutil")
+
+	var statuses []StoreStatus
+	for _, db := range findNSSDBs() {
+		status := StoreStatus{Name: db.name, Path: db.dir}
+		if lookErr != nil {
+			status.Err = errNoCertutil
+		} else {
+			status.Installed = nssHoldsCert(db, cert)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
@@ -1,39 +1,91 @@
 package certs
 
 import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
+	"strings"
 )
 
-// InstallCA installs the CA certificate into the system trust store
-func InstallCA() error {
+// Trust stores InstallCA writes to on Linux. Other systems always use the
+// current user's store.
+const (
+	// TrustStoreAll uses the system store and the NSS databases
+	TrustStoreAll = "all"
+	// TrustStoreSystem uses the system store only (requires root)
+	TrustStoreSystem = "system"
+	// TrustStoreUser uses the NSS databases of Firefox and Chromium only,
+	// so nothing outside the home directory is changed
+	TrustStoreUser = "user"
+)
+
+// trustStore is the set of trust stores InstallCA writes to
+var trustStore = TrustStoreAll
+
+// SetTrustStore sets which trust stores InstallCA writes to on Linux
+func SetTrustStore(mode string) error {
+	switch mode {
+	case "":
+		trustStore = TrustStoreAll
+	case TrustStoreAll, TrustStoreSystem, TrustStoreUser:
+		trustStore = mode
+	default:
+		return fmt.Errorf("unknown trust store %q (expected system, user or all)", mode)
+	}
+	return nil
+}
+
+// NeedsRoot reports whether InstallCA will ask for root privileges
+func NeedsRoot() bool {
+	return runtime.GOOS == "linux" && trustStore != TrustStoreUser && os.Geteuid() != 0
+}
+
+// InstallCA installs the CA certificate into the system trust store. The
+// warnings are problems with optional stores (the NSS databases when the
+// system store is used too) that don't make the install fail.
+func InstallCA() (warnings []error, err error) {
 	certPath, err := GetCACertPath()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	switch runtime.GOOS {
 	case "darwin":
-		return installCADarwin(certPath)
+		return nil, installCADarwin(certPath)
 	case "linux":
 		return installCALinux(certPath)
 	case "windows":
-		return installCAWindows(certPath)
+		return nil, installCAWindows(certPath)
 	default:
-		return fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
+		return nil, fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
 	}
 }
 
-// UninstallCA removes the CA certificate from the system trust store
+// UninstallCA removes the current CA certificate from the system trust store
 func UninstallCA() error {
+	cert, err := LoadCACert()
+	if err != nil {
+		return err
+	}
+	return UninstallCert(cert)
+}
+
+// UninstallCert removes a certificate from the system trust store. It is
+// matched by fingerprint, so other certificates with the same name (e.g.
+// a newer applink CA) are left alone.
+func UninstallCert(cert *x509.Certificate) error {
 	switch runtime.GOOS {
 	case "darwin":
-		return uninstallCADarwin()
+		return uninstallCertDarwin(cert)
 	case "linux":
-		return uninstallCALinux()
+		return uninstallCertLinux(cert)
 	case "windows":
-		return uninstallCAWindows()
+		return uninstallCertWindows(cert)
 	default:
 		return fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
 	}
@@ -56,10 +108,12 @@ func installCADarwin(certPath string) error {
 	return nil
 }
 
-// uninstallCADarwin removes the CA from the macOS login keychain
-func uninstallCADarwin() error {
+// uninstallCertDarwin removes a certificate and its trust settings from
+// the macOS login keychain
+func uninstallCertDarwin(cert *x509.Certificate) error {
 	cmd := exec.Command("security", "delete-certificate",
-		"-c", "applink Local CA",
+		"-Z", thumbprint(cert),
+		"-t",
 		"login.keychain",
 	)
 
@@ -71,54 +125,189 @@ func uninstallCADarwin() error {
 	return nil
 }
 
-// installCALinux installs the CA into the system trust store
-// This works for Debian/Ubuntu-based systems. Other distros may vary.
-func installCALinux(certPath string) error {
-	// Try the Debian/Ubuntu approach first
-	destPath := "/usr/local/share/ca-certificates/applink-ca.crt"
+// linuxStore is a distro's system trust store
+type linuxStore struct {
+	name   string
+	anchor string   // Where the CA certificate is copied
+	update []string // Rebuilds the trust bundle from the anchors
+}
 
-	// Copy certificate (requires sudo)
-	cpCmd := exec.Command("sudo", "cp", certPath, destPath)
-	if output, err := cpCmd.CombinedOutput(); err != nil {
-		// Try the RHEL/Fedora approach
-		destPath = "/etc/pki/ca-trust/source/anchors/applink-ca.crt"
-		cpCmd = exec.Command("sudo", "cp", certPath, destPath)
-		if output2, err := cpCmd.CombinedOutput(); err != nil {
-			return fmt.Errorf("failed to copy CA certificate: %w\nOutput: %s", err, string(output2))
+// linuxStores are the anchor-directory trust stores, in the order they
+// are tried. The first one whose directory exists is used.
+var linuxStores = []linuxStore{
+	{"Debian/Ubuntu", "/usr/local/share/ca-certificates/applink-ca.crt", []string{"update-ca-certificates"}},
+	{"RHEL/Fedora", "/etc/pki/ca-trust/source/anchors/applink-ca.crt", []string{"update-ca-trust"}},
+	{"openSUSE", "/etc/pki/trust/anchors/applink-ca.crt", []string{"update-ca-certificates"}},
+}
+
+// p11kitAnchorDir is where `trust anchor --store` (Arch) keeps anchors
+const p11kitAnchorDir = "/etc/ca-certificates/trust-source"
+
+// detectLinuxStore returns the system trust store of this distro, or nil
+// if p11-kit's `trust anchor` should be used (Arch)
+func detectLinuxStore() (*linuxStore, error) {
+	for i := range linuxStores {
+		if info, err := os.Stat(filepath.Dir(linuxStores[i].anchor)); err == nil && info.IsDir() {
+			return &linuxStores[i], nil
 		}
-		_ = output // Debian/Ubuntu attempt failed, but RHEL/Fedora worked
+	}
+	if _, err := exec.LookPath("trust"); err == nil {
+		return nil, nil
+	}
+	return nil, fmt.Errorf("no supported system trust store found (looked for update-ca-certificates, update-ca-trust and p11-kit)")
+}
+
+// installCALinux installs the CA into the system trust store and/or the
+// NSS databases used by Firefox and Chromium, depending on SetTrustStore.
+// Alongside the system store, NSS problems are only warnings.
+func installCALinux(certPath string) ([]error, error) {
+	switch trustStore {
+	case TrustStoreSystem:
+		return nil, installCALinuxSystem(certPath)
+	case TrustStoreUser:
+		return nil, installCANSS(certPath)
+	}
 
-		// Update trust on RHEL/Fedora
-		updateCmd := exec.Command("sudo", "update-ca-trust")
-		if output, err := updateCmd.CombinedOutput(); err != nil {
-			return fmt.Errorf("failed to update CA trust: %w\nOutput: %s", err, string(output))
+	if err := installCALinuxSystem(certPath); err != nil {
+		return nil, err
+	}
+	if err := installCANSS(certPath); err != nil {
+		return []error{fmt.Errorf("CA installed in the system trust store, but not for Firefox/Chromium: %w", err)}, nil
+	}
+	return nil, nil
+}
+
+// installCALinuxSystem copies the CA into the distro's anchor directory
+// (requires root), or stores it with p11-kit on Arch
+func installCALinuxSystem(certPath string) error {
+	store, err := detectLinuxStore()
+	if err != nil {
+		return err
+	}
+
+	if store == nil {
+		if err := runAsRoot("trust", "anchor", "--store", certPath); err != nil {
+			return fmt.Errorf("failed to add CA with trust anchor: %w", err)
 		}
 		return nil
 	}
 
-	// Update certificates on Debian/Ubuntu
-	updateCmd := exec.Command("sudo", "update-ca-certificates")
-	if output, err := updateCmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to update CA certificates: %w\nOutput: %s", err, string(output))
+	if err := runAsRoot("cp", certPath, store.anchor); err != nil {
+		return fmt.Errorf("failed to copy CA certificate: %w", err)
+	}
+	if err := runAsRoot(store.update[0], store.update[1:]...); err != nil {
+		return fmt.Errorf("failed to update CA trust (%s): %w", store.name, err)
 	}
-
 	return nil
 }
 
-// uninstallCALinux removes the CA from the system trust store
-func uninstallCALinux() error {
-	// Try Debian/Ubuntu path first
-	rmCmd := exec.Command("sudo", "rm", "-f", "/usr/local/share/ca-certificates/applink-ca.crt")
-	rmCmd.Run() // Ignore errors
+// uninstallCertLinux removes this certificate from the system trust store
+// and the NSS databases
+func uninstallCertLinux(cert *x509.Certificate) error {
+	for _, store := range linuxStores {
+		if !fileHoldsCert(store.anchor, cert) {
+			continue
+		}
+		if err := runAsRoot("rm", "-f", store.anchor); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", store.anchor, err)
+		}
+		if err := runAsRoot(store.update[0], store.update[1:]...); err != nil {
+			return fmt.Errorf("failed to update CA trust (%s): %w", store.name, err)
+		}
+	}
+
+	if p11kitAnchorFile(cert) != "" {
+		// trust anchor --remove identifies the anchor by its certificate,
+		// which may no longer be on disk (e.g. after a rotation)
+		path, err := writeTempCert(cert)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(path)
+		if err := runAsRoot("trust", "anchor", "--remove", path); err != nil {
+			return fmt.Errorf("failed to remove CA with trust anchor: %w", err)
+		}
+	}
+
+	return uninstallCertNSS(cert)
+}
+
+// p11kitAnchorFile returns the p11-kit anchor file holding the
+// certificate, or "" if there is none
+func p11kitAnchorFile(cert *x509.Certificate) string {
+	paths, _ := filepath.Glob(filepath.Join(p11kitAnchorDir, "*.p11-kit"))
+	for _, path := range paths {
+		if fileHoldsCert(path, cert) {
+			return path
+		}
+	}
+	return ""
+}
+
+// linuxSystemStatus reports whether the system trust store holds cert
+func linuxSystemStatus(cert *x509.Certificate) StoreStatus {
+	status := StoreStatus{Name: "system"}
+	store, err := detectLinuxStore()
+	switch {
+	case err != nil:
+		status.Err = err
+		return status
+	case store == nil:
+		status.Name = "system (p11-kit)"
+		status.Path = p11kitAnchorDir
+		status.Installed = p11kitAnchorFile(cert) != ""
+		return status
+	}
 
-	// Try RHEL/Fedora path
-	rmCmd = exec.Command("sudo", "rm", "-f", "/etc/pki/ca-trust/source/anchors/applink-ca.crt")
-	rmCmd.Run() // Ignore errors
+	status.Name = "system (" + store.name + ")"
+	status.Path = store.anchor
+	for _, s := range linuxStores {
+		if fileHoldsCert(s.anchor, cert) {
+			status.Installed = true
+		}
+	}
+	return status
+}
+
+// writeTempCert writes a certificate to a temporary PEM file
+func writeTempCert(cert *x509.Certificate) (string, error) {
+	f, err := os.CreateTemp("", "applink-ca-*.pem")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer f.Close()
+	if err := pem.Encode(f, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	return f.Name(), nil
+}
 
-	// Update on both systems
-	exec.Command("sudo", "update-ca-certificates").Run()
-	exec.Command("sudo", "update-ca-trust").Run()
+// elevators are the commands tried, in order, to run a command as root
+var elevators = []string{"sudo", "doas", "pkexec"}
 
+// runAsRoot runs a command with root privileges. It runs directly if we
+// are root already, and otherwise through sudo, doas or pkexec.
+func runAsRoot(name string, args ...string) error {
+	var cmd *exec.Cmd
+	if os.Geteuid() == 0 {
+		cmd = exec.Command(name, args...)
+	} else {
+		elevator := ""
+		for _, candidate := range elevators {
+			if _, err := exec.LookPath(candidate); err == nil {
+				elevator = candidate
+				break
+			}
+		}
+		if elevator == "" {
+			return fmt.Errorf("root privileges are needed, but none of %s was found; run as root, or use --trust-store user", strings.Join(elevators, ", "))
+		}
+		cmd = exec.Command(elevator, append([]string{name}, args...)...)
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w\nOutput: %s", err, string(output))
+	}
 	return nil
 }
 
@@ -133,10 +322,10 @@ func installCAWindows(certPath string) error {
 	return nil
 }
 
-// uninstallCAWindows removes the CA from the Windows user certificate store
-func uninstallCAWindows() error {
-	// Find and delete by common name
-	cmd := exec.Command("certutil", "-delstore", "-user", "Root", "applink Local CA")
+// uninstallCertWindows removes a certificate from the Windows user
+// certificate store
+func uninstallCertWindows(cert *x509.Certificate) error {
+	cmd := exec.Command("certutil", "-delstore", "-user", "Root", thumbprint(cert))
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("failed to uninstall CA on Windows: %w\nOutput: %s", err, string(output))
@@ -145,20 +334,71 @@ func uninstallCAWindows() error {
 	return nil
 }
 
-// IsCAInstalled checks if the CA is installed in the system trust store
+// StoreStatus is whether a trust store holds the CA certificate
+type StoreStatus struct {
+	Name      string // e.g. "system (Debian/Ubuntu)", "Firefox (default-release)"
+	Path      string // Anchor file or NSS database
+	Installed bool
+	Err       error // Set if the store couldn't be checked
+}
+
+// TrustStores reports each trust store that InstallCA writes to and
+// whether it holds cert. On Linux these are the system store and every
+// NSS database (Chromium's ~/.pki/nssdb and Firefox profiles).
+func TrustStores(cert *x509.Certificate) []StoreStatus {
+	switch runtime.GOOS {
+	case "darwin":
+		return []StoreStatus{{Name: "login keychain", Installed: IsCertInstalled(cert)}}
+	case "linux":
+		return linuxTrustStores(cert, trustStore)
+	case "windows":
+		return []StoreStatus{{Name: "user Root store", Installed: IsCertInstalled(cert)}}
+	default:
+		return nil
+	}
+}
+
+// linuxTrustStores reports the Linux trust stores selected by mode
+func linuxTrustStores(cert *x509.Certificate, mode string) []StoreStatus {
+	var statuses []StoreStatus
+	if mode != TrustStoreUser {
+		statuses = append(statuses, linuxSystemStatus(cert))
+	}
+	if mode != TrustStoreSystem {
+		statuses = append(statuses, nssStatus(cert)...)
+	}
+	return statuses
+}
+
+// IsCAInstalled checks if the CA is installed in the system trust store.
+// On Linux the NSS databases only count with --trust-store user; in "all"
+// mode they are best effort, as in InstallCA, and TrustGaps reports them.
 func IsCAInstalled() bool {
 	switch runtime.GOOS {
 	case "darwin":
 		cmd := exec.Command("security", "find-certificate", "-c", "applink Local CA", "login.keychain")
 		return cmd.Run() == nil
 	case "linux":
-		// Check common locations
-		cmd := exec.Command("test", "-f", "/usr/local/share/ca-certificates/applink-ca.crt")
-		if cmd.Run() == nil {
-			return true
+		cert, err := LoadCACert()
+		if err != nil {
+			return false
 		}
-		cmd = exec.Command("test", "-f", "/etc/pki/ca-trust/source/anchors/applink-ca.crt")
-		return cmd.Run() == nil
+		if trustStore != TrustStoreUser {
+			return linuxSystemStatus(cert).Installed
+		}
+		checked := 0
+		for _, store := range nssStatus(cert) {
+			// Without certutil the NSS databases can't be checked or
+			// installed into, so they don't count
+			if store.Err == errNoCertutil {
+				continue
+			}
+			if !store.Installed {
+				return false
+			}
+			checked++
+		}
+		return checked > 0
 	case "windows":
 		cmd := exec.Command("certutil", "-verifystore", "-user", "Root", "applink Local CA")
 		return cmd.Run() == nil
@@ -166,3 +406,68 @@ func IsCAInstalled() bool {
 		return false
 	}
 }
+
+// TrustGaps lists the NSS databases missing the CA in "all" mode on Linux,
+// which IsCAInstalled doesn't require
+func TrustGaps() []error {
+	if runtime.GOOS != "linux" || trustStore != TrustStoreAll {
+		return nil
+	}
+	cert, err := LoadCACert()
+	if err != nil {
+		return nil
+	}
+
+	var gaps []error
+	for _, store := range nssStatus(cert) {
+		switch {
+		case store.Err == errNoCertutil || store.Installed:
+		case store.Err != nil:
+			gaps = append(gaps, fmt.Errorf("could not check %s: %w", store.Name, store.Err))
+		default:
+			gaps = append(gaps, fmt.Errorf("CA not trusted by %s (%s)", store.Name, store.Path))
+		}
+	}
+	return gaps
+}
+
+// IsCertInstalled checks if this specific certificate (matched by
+// fingerprint) is in any trust store applink can install into
+func IsCertInstalled(cert *x509.Certificate) bool {
+	switch runtime.GOOS {
+	case "darwin":
+		cmd := exec.Command("security", "find-certificate", "-a", "-Z", "-c", cert.Subject.CommonName, "login.keychain")
+		output, err := cmd.Output()
+		return err == nil && strings.Contains(string(output), thumbprint(cert))
+	case "linux":
+		for _, store := range linuxTrustStores(cert, TrustStoreAll) {
+			if store.Installed {
+				return true
+			}
+		}
+		return false
+	case "windows":
+		cmd := exec.Command("certutil", "-verifystore", "-user", "Root", thumbprint(cert))
+		return cmd.Run() == nil
+	default:
+		return false
+	}
+}
+
+// fileHoldsCert reports whether a PEM file contains the certificate
+func fileHoldsCert(path string, cert *x509.Certificate) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return false
+		}
+		if block.Type == "CERTIFICATE" && bytes.Equal(block.Bytes, cert.Raw) {
+			return true
+		}
+	}
+}
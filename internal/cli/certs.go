@@ -0,0 +1,319 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jaknapp/applink/internal/certs"
+	"github.com/jaknapp/applink/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var certsCmd = &cobra.Command{
+	Use:   "certs",
+	Short: "Manage the local certificate authority",
+	Long: `Inspect, rotate, export or remove the local Certificate Authority that
+applink uses for HTTPS OAuth callbacks (see 'applink init').`,
+}
+
+var certsStatusCmd = &cobra.Command{
+	Use:         "status",
+	Short:       "Show the CA's subject, fingerprint, expiry and trust state",
+	Args:        cobra.NoArgs,
+	RunE:        runCertsStatus,
+	Annotations: outputCommand,
+}
+
+var certsRotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Replace the CA with a new one",
+	Long: `Generate a new CA, install it in the system trust store, then remove
+the old CA from the trust store. The old CA is matched by fingerprint, so
+only that exact certificate is removed.
+
+Use --key-storage to move the CA private key into the keychain (keyring),
+encrypt it with a key held there (encrypted), or keep it as a plain file.`,
+	Example: `  applink certs rotate
+  applink certs rotate --key-storage keyring`,
+	Args: cobra.NoArgs,
+	RunE: runCertsRotate,
+}
+
+var certsUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Remove the CA from the trust store and delete its files",
+	Long: `Remove the CA certificate from the system trust store (matched by
+fingerprint) and delete everything under ~/.applink/certs.
+
+Services that need HTTPS callbacks (Slack) will set up a new CA on the
+next login.`,
+	Args: cobra.NoArgs,
+	RunE: runCertsUninstall,
+}
+
+var certsExportCmd = &cobra.Command{
+	Use:   "export [file]",
+	Short: "Write the CA certificate in PEM format",
+	Long: `Write the CA certificate (not its private key) in PEM format to a file,
+or to stdout if no file is given. Use it to trust the CA in tools with
+their own trust store.`,
+	Example: `  applink certs export applink-ca.pem
+  applink certs export | openssl x509 -noout -text`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runCertsExport,
+}
+
+var (
+	rotateKeyStorage string
+	trustStoreFlag   string // --trust-store on init, login and certs rotate
+)
+
+// trustStoreUsage is the help text of --trust-store
+const trustStoreUsage = "Linux trust stores to install the CA into: system, user (Firefox/Chromium only, no root) or all (default: trust_store in config.json)"
+
+func init() {
+	certsRotateCmd.Flags().StringVar(&trustStoreFlag, "trust-store", "", trustStoreUsage)
+	certsRotateCmd.Flags().StringVar(&rotateKeyStorage, "key-storage", "", "Where to keep the new CA key: encrypted, keyring or file (default: ca_key_storage in config.json)")
+
+	certsCmd.AddCommand(certsStatusCmd)
+	certsCmd.AddCommand(certsRotateCmd)
+	certsCmd.AddCommand(certsUninstallCmd)
+	certsCmd.AddCommand(certsExportCmd)
+}
+
+// certsStatusOutput is the schema of `applink certs status --output json|yaml`
+type certsStatusOutput struct {
+	Exists      bool              `json:"exists"`
+	Path        string            `json:"path"`
+	Subject     string            `json:"subject"`
+	Fingerprint string            `json:"fingerprint_sha256"`
+	NotBefore   string            `json:"not_before"`
+	NotAfter    string            `json:"not_after"`
+	Installed   bool              `json:"installed"`
+	KeyStorage  string            `json:"key_storage"` // encrypted, keyring or file
+	TrustStores []trustStoreEntry `json:"trust_stores"`
+}
+
+// trustStoreEntry is one trust store in `applink certs status`
+type trustStoreEntry struct {
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	Installed bool   `json:"installed"`
+	Error     string `json:"error"` // Why the store couldn't be checked
+}
+
+func runCertsStatus(cmd *cobra.Command, args []string) error {
+	format, err := structuredFormat()
+	if err != nil {
+		return err
+	}
+
+	certPath, err := certs.GetCACertPath()
+	if err != nil {
+		return err
+	}
+	status := &certsStatusOutput{Path: certPath, TrustStores: []trustStoreEntry{}}
+
+	exists, err := certs.CAExists()
+	if err != nil {
+		return fmt.Errorf("failed to check CA status: %w", err)
+	}
+	if exists {
+		cert, err := certs.LoadCACert()
+		if err != nil {
+			return err
+		}
+		status.Exists = true
+		status.Subject = cert.Subject.String()
+		status.Fingerprint = certs.Fingerprint(cert)
+		status.NotBefore = timestamp(cert.NotBefore)
+		status.NotAfter = timestamp(cert.NotAfter)
+		status.Installed = certs.IsCertInstalled(cert)
+		status.KeyStorage, _ = certs.CAKeyStorage()
+		for _, store := range certs.TrustStores(cert) {
+			entry := trustStoreEntry{Name: store.Name, Path: store.Path, Installed: store.Installed}
+			if store.Err != nil {
+				entry.Error = store.Err.Error()
+			}
+			status.TrustStores = append(status.TrustStores, entry)
+		}
+	}
+
+	if format != "" {
+		return writeStructured(status, format)
+	}
+
+	if !status.Exists {
+		fmt.Println("No CA certificate. Run 'applink init' to create one.")
+		return nil
+	}
+
+	fmt.Printf("Subject:     %s\n", status.Subject)
+	fmt.Printf("SHA-256:     %s\n", status.Fingerprint)
+	fmt.Printf("Not before:  %s\n", status.NotBefore)
+	fmt.Printf("Not after:   %s\n", status.NotAfter)
+	fmt.Printf("Path:        %s\n", status.Path)
+	fmt.Printf("Private key: %s\n", keyStorageLabel(status.KeyStorage))
+	fmt.Println("Trust stores:")
+	for _, store := range status.TrustStores {
+		switch {
+		case store.Error != "":
+			fmt.Printf("  ⚠ %s: %s\n", store.Name, store.Error)
+		case store.Installed:
+			fmt.Printf("  ✓ %s\n", store.Name)
+		default:
+			fmt.Printf("  ✗ %s: not installed (run 'applink init')\n", store.Name)
+		}
+	}
+	return nil
+}
+
+// keyStorageLabel describes where the CA private key is kept
+func keyStorageLabel(storage string) string {
+	switch storage {
+	case certs.KeyStorageKeyring:
+		return "in the system keychain"
+	case certs.KeyStorageEncrypted:
+		return "encrypted, with the key in the system keychain"
+	case certs.KeyStorageFile:
+		return "unencrypted file (see 'applink certs rotate --key-storage')"
+	default:
+		return "unknown"
+	}
+}
+
+func runCertsRotate(cmd *cobra.Command, args []string) error {
+	if err := applyTrustStoreFlag(); err != nil {
+		return err
+	}
+	if rotateKeyStorage != "" {
+		if err := certs.SetKeyStorage(rotateKeyStorage); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println("Generating and installing a new CA certificate...")
+
+	removed, warnings, err := certs.RotateCA()
+	printTrustWarnings(warnings)
+	if err != nil {
+		return err
+	}
+
+	cert, err := certs.LoadCACert()
+	if err != nil {
+		return err
+	}
+
+	if removed != nil {
+		fmt.Printf("✓ Removed old CA: %s\n", certs.Fingerprint(removed))
+	}
+	fmt.Printf("✓ Installed new CA: %s\n", certs.Fingerprint(cert))
+	fmt.Printf("  Valid until %s\n", cert.NotAfter.Format("2006-01-02"))
+	if storage, err := certs.CAKeyStorage(); err == nil {
+		fmt.Printf("  Private key: %s\n", keyStorageLabel(storage))
+	}
+	return nil
+}
+
+// setupCerts applies ca_key_storage and trust_store from config.json. A
+// broken config file is reported by the commands that read it.
+func setupCerts() {
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return
+	}
+	if err := certs.SetKeyStorage(settings.CAKeyStorage); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: ca_key_storage in config.json: %v\n", err)
+	}
+	if err := certs.SetTrustStore(settings.TrustStore); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: trust_store in config.json: %v\n", err)
+	}
+}
+
+// applyTrustStoreFlag applies --trust-store, which overrides trust_store
+// in config.json
+func applyTrustStoreFlag() error {
+	if trustStoreFlag == "" {
+		return nil
+	}
+	return certs.SetTrustStore(trustStoreFlag)
+}
+
+// printRootNote tells the user a privilege prompt is coming
+func printRootNote() {
+	if certs.NeedsRoot() {
+		fmt.Println("Note: On Linux, this requires root access (via sudo, doas or pkexec).")
+		fmt.Println("      Use --trust-store user to only trust the CA in Firefox and Chromium.")
+		fmt.Println()
+	}
+}
+
+// printTrustWarnings prints the trust stores InstallCA couldn't update
+func printTrustWarnings(warnings []error) {
+	for _, w := range warnings {
+		fmt.Printf("Warning: %v\n", w)
+	}
+}
+
+// printTrustGaps warns about NSS databases missing a CA the system trusts
+func printTrustGaps() {
+	gaps := certs.TrustGaps()
+	if len(gaps) == 0 {
+		return
+	}
+	printTrustWarnings(gaps)
+	fmt.Println("  Run 'applink init --trust-store user' to add the CA to Firefox/Chromium.")
+}
+
+func runCertsUninstall(cmd *cobra.Command, args []string) error {
+	if cert, err := certs.LoadCACert(); err == nil {
+		if certs.IsCertInstalled(cert) {
+			if err := certs.UninstallCert(cert); err != nil {
+				return err
+			}
+			fmt.Printf("✓ Removed CA %s from the trust store\n", certs.Fingerprint(cert))
+		}
+	}
+
+	certsDir, err := certs.GetCertsDir()
+	if err != nil {
+		return err
+	}
+	if err := certs.RemoveCAFiles(); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Deleted %s\n", certsDir)
+	return nil
+}
+
+func runCertsExport(cmd *cobra.Command, args []string) error {
+	certPath, err := certs.GetCACertPath()
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(certPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no CA certificate. Run: applink init")
+		}
+		return fmt.Errorf("failed to read CA certificate: %w", err)
+	}
+
+	if len(args) == 0 || args[0] == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+
+	path := args[0]
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	fmt.Fprintf(os.Stderr, "✓ Wrote CA certificate to %s\n", path)
+	return nil
+}
@@ -0,0 +1,393 @@
+package cli
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jaknapp/applink/internal/certs"
+	"github.com/jaknapp/applink/internal/config"
+	"github.com/jaknapp/applink/internal/mcp"
+	"github.com/jaknapp/applink/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose common setup problems",
+	Long: `Check the things a login or MCP setup depends on: keychain access,
+the OAuth callback port, the local CA, the browser opener, credentials and
+tokens for each service, npx, and Cursor's mcp.json.
+
+Each check passes (✓), warns (⚠) or fails (✗), with a suggested fix. The
+command exits non-zero if any check fails.`,
+	Example: `  applink doctor
+  applink doctor --output json`,
+	Args:        cobra.NoArgs,
+	RunE:        runDoctor,
+	Annotations: outputCommand,
+}
+
+// Check results
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+// doctorCheck is the result of one diagnostic
+type doctorCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"` // pass, warn or fail
+	Message string `json:"message"`
+	Fix     string `json:"fix"` // Suggested fix, if the check didn't pass
+}
+
+// doctorOutput is the schema of `applink doctor --output json|yaml`
+type doctorOutput struct {
+	Checks []doctorCheck `json:"checks"`
+}
+
+// caExpiryWarning is how long before expiry the CA is reported
+const caExpiryWarning = 30 * 24 * time.Hour
+
+// tokenExpiryWarning is how long before expiry a token is reported
+const tokenExpiryWarning = 7 * 24 * time.Hour
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	format, err := structuredFormat()
+	if err != nil {
+		return err
+	}
+
+	var checks []doctorCheck
+	keychain := checkKeychain()
+	keychainOK := keychain.Status == checkPass
+	checks = append(checks, keychain)
+
+	settings, settingsCheck := checkSettings()
+	checks = append(checks, settingsCheck)
+	checks = append(checks, checkCallbackPorts(settings)...)
+	checks = append(checks, checkCA()...)
+	checks = append(checks, checkBrowserOpener())
+	checks = append(checks, checkCredentials(keychainOK)...)
+	checks = append(checks, checkTokens(keychainOK)...)
+	checks = append(checks, checkMCP()...)
+
+	if format != "" {
+		if err := writeStructured(&doctorOutput{Checks: checks}, format); err != nil {
+			return err
+		}
+	} else {
+		printDoctorChecks(checks)
+	}
+
+	failed := 0
+	for _, check := range checks {
+		if check.Status == checkFail {
+			failed++
+		}
+	}
+	if failed > 0 {
+		cmd.SilenceUsage = true
+		return &exitError{code: 1, err: fmt.Errorf("%d check(s) failed", failed)}
+	}
+	return nil
+}
+
+func printDoctorChecks(checks []doctorCheck) {
+	for _, check := range checks {
+		symbol := "✓"
+		switch check.Status {
+		case checkWarn:
+			symbol = "⚠"
+		case checkFail:
+			symbol = "✗"
+		}
+		fmt.Printf("%s %s: %s\n", symbol, check.Name, check.Message)
+		if check.Fix != "" {
+			for _, line := range strings.Split(check.Fix, "\n") {
+				fmt.Printf("    %s\n", line)
+			}
+		}
+	}
+}
+
+func pass(name, message string) doctorCheck {
+	return doctorCheck{Name: name, Status: checkPass, Message: message}
+}
+
+func warn(name, message, fix string) doctorCheck {
+	return doctorCheck{Name: name, Status: checkWarn, Message: message, Fix: fix}
+}
+
+func fail(name, message, fix string) doctorCheck {
+	return doctorCheck{Name: name, Status: checkFail, Message: message, Fix: fix}
+}
+
+func checkKeychain() doctorCheck {
+	if err := storage.CheckKeychain(); err != nil {
+		return fail("keychain", fmt.Sprintf("not reachable: %v", err),
+			"Start a keychain daemon (e.g. gnome-keyring), or use environment variables:\n"+
+				"APPLINK_<SERVICE>_CLIENT_ID and APPLINK_<SERVICE>_CLIENT_SECRET")
+	}
+	return pass("keychain", "reachable")
+}
+
+func checkSettings() (*config.Settings, doctorCheck) {
+	path, _ := config.GetSettingsPath()
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return &config.Settings{}, fail("config", err.Error(), "Fix or remove "+path)
+	}
+	if _, err := callbackTimeout(settings); err != nil {
+		return settings, fail("config", err.Error(), "Fix callback_timeout in "+path)
+	}
+	if _, err := successRedirectURL(settings); err != nil {
+		return settings, fail("config", err.Error(), "Fix success_redirect_url in "+path)
+	}
+	if err := certs.SetKeyStorage(settings.CAKeyStorage); err != nil {
+		return settings, fail("config", err.Error(), "Fix ca_key_storage in "+path)
+	}
+	if err := certs.SetTrustStore(settings.TrustStore); err != nil {
+		return settings, fail("config", err.Error(), "Fix trust_store in "+path)
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return settings, pass("config", "no config file (using defaults)")
+	}
+	return settings, pass("config", path+" is valid")
+}
+
+// checkCallbackPorts tries to bind each OAuth service's callback ports
+func checkCallbackPorts(settings *config.Settings) []doctorCheck {
+	var checks []doctorCheck
+	for _, service := range config.AllServices() {
+		if service.AuthType != config.AuthTypeOAuth {
+			continue
+		}
+
+		name := "callback port (" + service.ID + ")"
+		ports := callbackPorts(service, settings)
+		if len(ports) == 1 && ports[0] == 0 {
+			checks = append(checks, pass(name, "any free port"))
+			continue
+		}
+
+		var free, busy []string
+		for _, port := range ports {
+			listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+			if err != nil {
+				busy = append(busy, strconv.Itoa(port))
+				continue
+			}
+			listener.Close()
+			free = append(free, strconv.Itoa(port))
+		}
+
+		switch {
+		case len(free) == 0:
+			checks = append(checks, fail(name, "port "+strings.Join(busy, ", ")+" in use",
+				"Stop the process using it, or register another port and run:\n"+
+					"applink login "+service.ID+" --port <port>"))
+		case len(busy) > 0:
+			checks = append(checks, pass(name, "port "+strings.Join(free, ", ")+" free ("+strings.Join(busy, ", ")+" in use)"))
+		default:
+			checks = append(checks, pass(name, "port "+strings.Join(free, ", ")+" free"))
+		}
+	}
+	return checks
+}
+
+// checkCA checks the local CA used for HTTPS callbacks (Slack)
+func checkCA() []doctorCheck {
+	exists, err := certs.CAExists()
+	if err != nil {
+		return []doctorCheck{fail("CA", err.Error(), "")}
+	}
+	if !exists {
+		return []doctorCheck{warn("CA", "not created (needed for Slack's HTTPS callback)", "Run: applink init")}
+	}
+
+	var checks []doctorCheck
+	// The certificate checks don't need the key, which may be behind a
+	// locked keychain; its readability is reported separately below
+	caCert, err := certs.LoadCACert()
+	switch {
+	case err != nil:
+		checks = append(checks, fail("CA", err.Error(), "Run: applink init --force"))
+	case time.Now().After(caCert.NotAfter):
+		checks = append(checks, fail("CA", "expired on "+caCert.NotAfter.Format("2006-01-02"), "Run: applink init --force"))
+	case time.Until(caCert.NotAfter) < caExpiryWarning:
+		checks = append(checks, warn("CA", "expires on "+caCert.NotAfter.Format("2006-01-02"), "Run: applink init --force"))
+	case !certs.IsConstrained(caCert):
+		checks = append(checks, warn("CA", "not limited to localhost; a stolen CA key could sign certificates for any site",
+			"Replace it with a name-constrained CA: applink certs rotate"))
+	default:
+		checks = append(checks, pass("CA", "valid until "+caCert.NotAfter.Format("2006-01-02")+", limited to localhost"))
+	}
+
+	checks = append(checks, checkCAKey())
+
+	if caCert == nil {
+		return checks
+	}
+	for _, store := range certs.TrustStores(caCert) {
+		name := "CA trust (" + store.Name + ")"
+		switch {
+		case store.Err != nil:
+			checks = append(checks, warn(name, store.Err.Error(), ""))
+		case store.Installed:
+			checks = append(checks, pass(name, "installed"))
+		default:
+			checks = append(checks, warn(name, "not installed; browsers will warn about the callback certificate", "Run: applink init"))
+		}
+	}
+
+	return checks
+}
+
+// checkCAKey checks that the CA private key can be read, since signing
+// the callback certificate needs it
+func checkCAKey() doctorCheck {
+	keyStorage, err := certs.CAKeyStorage()
+	if err != nil {
+		return fail("CA key", err.Error(), "Run: applink init --force")
+	}
+	if _, _, err := certs.LoadCA(); err != nil {
+		if storage.IsKeychainError(err) {
+			return fail("CA key", "cannot be read: "+err.Error(), "Unlock the system keychain, or replace the CA: applink certs rotate")
+		}
+		return fail("CA key", "cannot be read: "+err.Error(), "Replace the CA: applink certs rotate")
+	}
+	if keyStorage == certs.KeyStorageFile {
+		return warn("CA key", "stored unencrypted in ~/.applink/certs",
+			"Move it to the keychain: applink certs rotate --key-storage encrypted")
+	}
+	return pass("CA key", keyStorageLabel(keyStorage))
+}
+
+// checkBrowserOpener checks the command used to open the login page
+func checkBrowserOpener() doctorCheck {
+	var opener string
+	switch runtime.GOOS {
+	case "darwin":
+		opener = "open"
+	case "linux":
+		opener = "xdg-open"
+	default:
+		return pass("browser", "uses the system default browser")
+	}
+
+	if _, err := exec.LookPath(opener); err != nil {
+		return warn("browser", opener+" not found; the login URL must be opened by hand",
+			"Install "+opener+" (e.g. the xdg-utils package)")
+	}
+	return pass("browser", opener+" found")
+}
+
+// checkCredentials reports where each OAuth service's client credentials
+// come from. Without a keychain only environment variables are checked.
+func checkCredentials(keychainOK bool) []doctorCheck {
+	var checks []doctorCheck
+	for _, service := range config.AllServices() {
+		if service.AuthType != config.AuthTypeOAuth {
+			continue
+		}
+
+		name := "credentials (" + service.ID + ")"
+		envPrefix := fmt.Sprintf("APPLINK_%s_", strings.ToUpper(service.ID))
+		hasID, hasSecret := os.Getenv(envPrefix+"CLIENT_ID") != "", os.Getenv(envPrefix+"CLIENT_SECRET") != ""
+		if hasID != hasSecret {
+			checks = append(checks, warn(name, "only one of "+envPrefix+"CLIENT_ID and "+envPrefix+"CLIENT_SECRET is set",
+				"Set both, or unset both to use the keychain"))
+			continue
+		}
+
+		if !keychainOK {
+			if hasID {
+				checks = append(checks, pass(name, "from environment variables"))
+			} else {
+				checks = append(checks, warn(name, "not set (keychain not reachable)", "Set "+envPrefix+"CLIENT_ID and "+envPrefix+"CLIENT_SECRET"))
+			}
+			continue
+		}
+
+		source, err := storage.CredentialsSource(service.ID)
+		switch {
+		case err != nil:
+			checks = append(checks, fail(name, err.Error(), "Set "+envPrefix+"CLIENT_ID and "+envPrefix+"CLIENT_SECRET"))
+		case source == storage.SourceEnv:
+			checks = append(checks, pass(name, "from environment variables"))
+		case source == storage.SourceKeychain:
+			checks = append(checks, pass(name, "from keychain"))
+		default:
+			checks = append(checks, warn(name, "not set up", "Run: applink setup "+service.ID))
+		}
+	}
+	return checks
+}
+
+// checkTokens reports expired and soon-to-expire tokens. Without a
+// keychain only APPLINK_<SERVICE>_TOKEN variables are checked.
+func checkTokens(keychainOK bool) []doctorCheck {
+	var checks []doctorCheck
+	for _, service := range config.AllServices() {
+		if !keychainOK && !storage.HasEnvToken(service.ID) {
+			continue
+		}
+
+		name := "token (" + service.ID + ")"
+		fix := "Run: applink login " + service.ID
+		token, source, err := storage.LookupToken(service.ID)
+		if source == storage.SourceEnv {
+			name = "token (" + service.ID + ", from env)"
+			fix = "Update APPLINK_" + strings.ToUpper(service.ID) + "_TOKEN"
+		}
+		switch {
+		case err != nil:
+			checks = append(checks, fail(name, err.Error(), ""))
+		case token == nil:
+			checks = append(checks, pass(name, "not logged in"))
+		case token.IsExpired():
+			checks = append(checks, fail(name, "expired on "+token.ExpiresAt.Format("2006-01-02"), fix))
+		case !token.ExpiresAt.IsZero() && time.Until(token.ExpiresAt) < tokenExpiryWarning:
+			checks = append(checks, warn(name, "expires on "+token.ExpiresAt.Format("2006-01-02"), fix))
+		default:
+			checks = append(checks, pass(name, "valid"))
+		}
+	}
+
+	if !keychainOK {
+		checks = append(checks, warn("tokens", "keychain tokens not checked (keychain not reachable)", ""))
+	}
+	return checks
+}
+
+// checkMCP checks npx (which runs the MCP servers) and Cursor's mcp.json
+func checkMCP() []doctorCheck {
+	var checks []doctorCheck
+
+	if _, err := exec.LookPath("npx"); err != nil {
+		checks = append(checks, warn("npx", "not found; MCP servers can't start", "Install Node.js from https://nodejs.org"))
+	} else {
+		checks = append(checks, pass("npx", "found"))
+	}
+
+	path, _ := mcp.CursorConfigPath()
+	cfg, err := mcp.LoadCursorConfig()
+	switch {
+	case err != nil:
+		checks = append(checks, fail("mcp.json", fmt.Sprintf("%s: %v", path, err), "Fix the JSON syntax, or move the file away and run: applink mcp install"))
+	case len(cfg.MCPServers) == 0:
+		checks = append(checks, pass("mcp.json", "no MCP servers configured"))
+	default:
+		checks = append(checks, pass("mcp.json", fmt.Sprintf("%d MCP server(s) configured", len(cfg.MCPServers))))
+	}
+
+	return checks
+}
@@ -0,0 +1,331 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jaknapp/applink/internal/bundle"
+	"github.com/jaknapp/applink/internal/config"
+	"github.com/jaknapp/applink/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export credentials and tokens to an encrypted file",
+	Long: `Write the OAuth client credentials, tokens and config.json service
+settings stored for each service to a passphrase-encrypted bundle, for
+moving them to another machine with 'applink import'.
+
+The bundle is encrypted with AES-256-GCM under a key derived from the
+passphrase (PBKDF2-SHA256). Tokens from APPLINK_<SERVICE>_TOKEN and
+credentials from environment variables are not exported.`,
+	Example: `  applink export --out applink-bundle.json
+  applink export --out slack.json --service slack`,
+	Args: cobra.NoArgs,
+	RunE: runExport,
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import credentials and tokens from an encrypted file",
+	Long: `Store the credentials, tokens and service settings from a bundle
+written by 'applink export'.
+
+When something is already stored and differs from the bundle, --conflict
+decides: ask (the default), skip, or overwrite. Identical entries are left
+alone. With --passphrase-stdin, pass skip or overwrite: stdin is used up by
+the passphrase.`,
+	Example: `  applink import applink-bundle.json
+  applink import applink-bundle.json --service linear --conflict overwrite`,
+	Args: cobra.ExactArgs(1),
+	RunE: runImport,
+}
+
+// Values of import --conflict
+const (
+	conflictAsk       = "ask"
+	conflictSkip      = "skip"
+	conflictOverwrite = "overwrite"
+)
+
+var (
+	bundleOut             string
+	bundleServices        []string
+	bundlePassphraseStdin bool
+	importConflict        string
+)
+
+func init() {
+	exportCmd.Flags().StringVar(&bundleOut, "out", "", "File to write the bundle to (- for stdout)")
+	exportCmd.Flags().StringSliceVar(&bundleServices, "service", nil, "Only export these services (repeatable or comma-separated)")
+	exportCmd.Flags().BoolVar(&bundlePassphraseStdin, "passphrase-stdin", false, "Read the passphrase from stdin instead of prompting")
+	exportCmd.MarkFlagRequired("out")
+
+	importCmd.Flags().StringSliceVar(&bundleServices, "service", nil, "Only import these services (repeatable or comma-separated)")
+	importCmd.Flags().BoolVar(&bundlePassphraseStdin, "passphrase-stdin", false, "Read the passphrase from stdin instead of prompting")
+	importCmd.Flags().StringVar(&importConflict, "conflict", conflictAsk, "What to do when an entry is already stored: ask, skip or overwrite")
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	services, err := bundleServiceFilter()
+	if err != nil {
+		return err
+	}
+	if err := storage.CheckKeychain(); err != nil {
+		return err
+	}
+	cmd.SilenceUsage = true
+
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return err
+	}
+
+	b := &bundle.Bundle{CreatedAt: time.Now().UTC()}
+	for _, service := range config.AllServices() {
+		if services != nil && !services[service.ID] {
+			continue
+		}
+
+		entry := bundle.Service{ID: service.ID, Settings: settings.Services[service.ID]}
+		if entry.Credentials, err = storage.GetStoredCredentials(service.ID); err != nil {
+			return fmt.Errorf("failed to read %s credentials: %w", service.ID, err)
+		}
+		if entry.Token, err = storage.GetStoredToken(service.ID); err != nil {
+			return fmt.Errorf("failed to read %s token: %w", service.ID, err)
+		}
+		if entry.Credentials == nil && entry.Token == nil && entry.Settings == nil {
+			continue
+		}
+		b.Services = append(b.Services, entry)
+	}
+	if len(b.Services) == 0 {
+		return fmt.Errorf("nothing to export")
+	}
+
+	passphrase, err := readPassphrase(true)
+	if err != nil {
+		return err
+	}
+	data, err := bundle.Encrypt(b, passphrase)
+	if err != nil {
+		return err
+	}
+
+	if bundleOut == "-" {
+		_, err := os.Stdout.Write(append(data, '\n'))
+		return err
+	}
+	if err := os.WriteFile(bundleOut, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", bundleOut, err)
+	}
+
+	fmt.Fprintf(os.Stderr, "✓ Exported %d service(s) to %s\n", len(b.Services), bundleOut)
+	for _, entry := range b.Services {
+		fmt.Fprintf(os.Stderr, "  %s: %s\n", entry.ID, bundleContents(entry))
+	}
+	return nil
+}
+
+func runImport(cmd *cobra.Command, args []string) error {
+	switch importConflict {
+	case conflictAsk, conflictSkip, conflictOverwrite:
+	default:
+		return fmt.Errorf("invalid --conflict %q (expected ask, skip or overwrite)", importConflict)
+	}
+	// The passphrase drains stdin, leaving nothing to answer conflicts with
+	if bundlePassphraseStdin && importConflict == conflictAsk && !assumeYes {
+		return fmt.Errorf("--passphrase-stdin can't be combined with --conflict ask\nPass --conflict skip or --conflict overwrite.")
+	}
+	services, err := bundleServiceFilter()
+	if err != nil {
+		return err
+	}
+	cmd.SilenceUsage = true
+
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", args[0], err)
+	}
+	passphrase, err := readPassphrase(false)
+	if err != nil {
+		return err
+	}
+	b, err := bundle.Decrypt(data, passphrase)
+	if err != nil {
+		return err
+	}
+
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return err
+	}
+	settingsChanged := false
+
+	for _, entry := range b.Services {
+		if services != nil && !services[entry.ID] {
+			continue
+		}
+		if _, err := config.GetService(entry.ID); err != nil {
+			fmt.Printf("⚠ %s: unknown service, skipped\n", entry.ID)
+			continue
+		}
+
+		if entry.Credentials != nil {
+			existing, err := storage.GetStoredCredentials(entry.ID)
+			if err != nil {
+				return err
+			}
+			if ok, err := importEntry(entry.ID, "credentials", existing != nil, sameJSON(existing, entry.Credentials)); err != nil {
+				return err
+			} else if ok {
+				if err := storage.StoreCredentials(entry.ID, entry.Credentials); err != nil {
+					return err
+				}
+			}
+		}
+
+		if entry.Token != nil {
+			existing, err := storage.GetStoredToken(entry.ID)
+			if err != nil {
+				return fmt.Errorf("failed to read %s token: %w", entry.ID, err)
+			}
+			if ok, err := importEntry(entry.ID, "token", existing != nil, sameJSON(existing, entry.Token)); err != nil {
+				return err
+			} else if ok {
+				if err := storage.StoreToken(entry.ID, entry.Token); err != nil {
+					return fmt.Errorf("failed to store %s token: %w", entry.ID, err)
+				}
+			}
+		}
+
+		if entry.Settings != nil {
+			existing := settings.Services[entry.ID]
+			if ok, err := importEntry(entry.ID, "settings", existing != nil, sameJSON(existing, entry.Settings)); err != nil {
+				return err
+			} else if ok {
+				if settings.Services == nil {
+					settings.Services = make(map[string]*config.ServiceSettings)
+				}
+				settings.Services[entry.ID] = entry.Settings
+				settingsChanged = true
+			}
+		}
+	}
+
+	if settingsChanged {
+		if err := config.SaveSettings(settings); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importEntry reports whether one part of a service should be written,
+// resolving conflicts with --conflict, and prints the outcome
+func importEntry(serviceID, what string, exists, same bool) (bool, error) {
+	switch {
+	case !exists:
+		fmt.Printf("✓ %s: %s imported\n", serviceID, what)
+		return true, nil
+	case same:
+		fmt.Printf("= %s: %s unchanged\n", serviceID, what)
+		return false, nil
+	}
+
+	overwrite := importConflict == conflictOverwrite
+	if importConflict == conflictAsk {
+		var err error
+		overwrite, err = confirmNo(fmt.Sprintf("%s: %s already stored with different values. Replace?", serviceID, what),
+			"Pass --conflict overwrite or --conflict skip.")
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if overwrite {
+		fmt.Printf("✓ %s: %s replaced\n", serviceID, what)
+	} else {
+		fmt.Printf("- %s: %s skipped (already stored)\n", serviceID, what)
+	}
+	return overwrite, nil
+}
+
+// bundleServiceFilter returns the services selected with --service, or
+// nil for all of them
+func bundleServiceFilter() (map[string]bool, error) {
+	if len(bundleServices) == 0 {
+		return nil, nil
+	}
+	selected := make(map[string]bool, len(bundleServices))
+	for _, name := range bundleServices {
+		if _, err := config.GetService(name); err != nil {
+			return nil, err
+		}
+		selected[name] = true
+	}
+	return selected, nil
+}
+
+// readPassphrase reads the bundle passphrase from stdin or a prompt.
+// New passphrases are prompted for twice.
+func readPassphrase(confirmNew bool) (string, error) {
+	if bundlePassphraseStdin {
+		passphrase, err := readSecretStdin()
+		if err != nil {
+			return "", err
+		}
+		if passphrase == "" {
+			return "", fmt.Errorf("passphrase cannot be empty")
+		}
+		return passphrase, nil
+	}
+	if nonInteractive() {
+		return "", inputRequired("the bundle passphrase", "Pipe it to --passphrase-stdin.")
+	}
+
+	passphrase, err := promptSecret(os.Stderr, "Passphrase: ")
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase cannot be empty")
+	}
+	if confirmNew {
+		again, err := promptSecret(os.Stderr, "Repeat passphrase: ")
+		if err != nil {
+			return "", fmt.Errorf("failed to read passphrase: %w", err)
+		}
+		if again != passphrase {
+			return "", fmt.Errorf("passphrases do not match")
+		}
+	}
+	return passphrase, nil
+}
+
+// bundleContents lists what a bundle holds for a service
+func bundleContents(entry bundle.Service) string {
+	var parts []string
+	if entry.Credentials != nil {
+		parts = append(parts, "credentials")
+	}
+	if entry.Token != nil {
+		parts = append(parts, "token")
+	}
+	if entry.Settings != nil {
+		parts = append(parts, "settings")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// sameJSON reports whether two values serialize identically
+func sameJSON(a, b interface{}) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(ja, jb)
+}
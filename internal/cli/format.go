@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jaknapp/applink/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// outputAnnotation marks the commands that accept --output
+const outputAnnotation = "applink/output"
+
+// outputCommand is the annotation for commands that accept --output
+var outputCommand = map[string]string{outputAnnotation: "true"}
+
+// checkOutputFlag rejects --output on commands that don't support it,
+// instead of silently printing text
+func checkOutputFlag(cmd *cobra.Command) error {
+	if outputFormat == "" {
+		return nil
+	}
+	if _, ok := cmd.Annotations[outputAnnotation]; !ok {
+		return fmt.Errorf("'%s' does not support --output", cmd.CommandPath())
+	}
+	return nil
+}
+
+// responseFormat returns the --output format for API responses: JSON by
+// default, and table and raw besides json and yaml
+func responseFormat() (output.Format, error) {
+	if outputFormat == "" {
+		return output.FormatJSON, nil
+	}
+	return output.ParseFormat(outputFormat)
+}
+
+// structuredFormat returns the --output format for commands whose default
+// output is text. An empty format means text.
+func structuredFormat() (output.Format, error) {
+	if outputFormat == "" {
+		return "", nil
+	}
+
+	format, err := output.ParseFormat(outputFormat)
+	if err != nil {
+		return "", err
+	}
+	if format != output.FormatJSON && format != output.FormatYAML {
+		return "", fmt.Errorf("unsupported output format for this command: %s (expected json or yaml)", format)
+	}
+	return format, nil
+}
+
+// writeStructured writes v to stdout. v is encoded through its JSON tags
+// so JSON and YAML output share one schema.
+func writeStructured(v interface{}, format output.Format) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	value, err := output.Decode(data)
+	if err != nil {
+		return err
+	}
+
+	return output.Write(os.Stdout, value, format)
+}
+
+// timestamp formats a time for structured output; the zero time is omitted
+func timestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,218 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jaknapp/applink/internal/api"
+	"github.com/jaknapp/applink/internal/config"
+	"github.com/jaknapp/applink/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	graphqlQuery      string
+	graphqlVars       []string
+	graphqlOperation  string
+	graphqlIntrospect string
+)
+
+var graphqlCmd = &cobra.Command{
+	Use:   "graphql <service> [query-file|-]",
+	Short: "Send an authenticated GraphQL query",
+	Long: `Send a GraphQL query to a service's GraphQL endpoint.
+
+The query is read from a file, from stdin ("-"), or from --query.
+Variables are passed with --var: name=value sends a string, and
+name:=value sends a raw JSON value (number, boolean, object, ...).
+
+If the response contains errors, they are printed to stderr and the
+command exits with a non-zero status.
+
+Use --introspect to dump the service's schema as a .graphql file.`,
+	Example: `  applink graphql linear --query '{ viewer { id name } }'
+  applink graphql linear issue.graphql --var id=ENG-123
+  applink graphql linear - --var first:=10 < issues.graphql
+  applink graphql linear --introspect linear.graphql`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runGraphQL,
+}
+
+func init() {
+	graphqlCmd.Flags().StringVarP(&graphqlQuery, "query", "q", "", "GraphQL query (instead of a query file)")
+	graphqlCmd.Flags().StringArrayVar(&graphqlVars, "var", nil, "Query variable as name=value or name:=json (repeatable)")
+	graphqlCmd.Flags().StringVar(&graphqlOperation, "operation", "", "Operation name, if the query defines several")
+	graphqlCmd.Flags().StringVar(&graphqlIntrospect, "introspect", "", "Write the schema in SDL to this file (\"-\" for stdout)")
+}
+
+func runGraphQL(cmd *cobra.Command, args []string) error {
+	serviceName := args[0]
+
+	service, err := config.GetService(serviceName)
+	if err != nil {
+		return err
+	}
+	if service.GraphQLURL == "" {
+		return fmt.Errorf("%s does not have a GraphQL API. Use: applink request %s", service.Name, serviceName)
+	}
+
+	token, err := storage.GetToken(serviceName)
+	if err != nil {
+		return fmt.Errorf("failed to get token: %w", err)
+	}
+	if token == nil {
+		return fmt.Errorf("not authenticated with %s. Run: applink login %s", serviceName, serviceName)
+	}
+
+	if graphqlIntrospect != "" {
+		cmd.SilenceUsage = true // Failures from here on aren't usage errors
+		return runIntrospection(service, token)
+	}
+
+	query, err := readGraphQLQuery(args[1:])
+	if err != nil {
+		return err
+	}
+
+	variables, err := parseGraphQLVars(graphqlVars)
+	if err != nil {
+		return err
+	}
+	cmd.SilenceUsage = true // Failures from here on aren't usage errors
+
+	debugLog("GraphQL: POST %s", service.GraphQLURL)
+
+	resp, err := api.DoGraphQL(newHTTPClient(), service, token, &api.GraphQLRequest{
+		Query:         query,
+		Variables:     variables,
+		OperationName: graphqlOperation,
+	})
+	if err != nil {
+		return graphQLFailure(err)
+	}
+
+	if len(resp.Data) > 0 && string(resp.Data) != "null" {
+		var prettyJSON bytes.Buffer
+		if err := json.Indent(&prettyJSON, resp.Data, "", "  "); err != nil {
+			fmt.Println(string(resp.Data))
+		} else {
+			fmt.Println(prettyJSON.String())
+		}
+	}
+
+	if len(resp.Errors) > 0 {
+		for _, e := range resp.Errors {
+			fmt.Fprintf(os.Stderr, "GraphQL error: %s\n", e)
+		}
+		return apiFailure(api.GraphQLErrors(service, resp.StatusCode, resp.Errors))
+	}
+
+	return nil
+}
+
+// runIntrospection fetches the service's schema and writes it as SDL
+func runIntrospection(service *config.Service, token *storage.Token) error {
+	resp, err := api.DoGraphQL(newHTTPClient(), service, token, &api.GraphQLRequest{
+		Query:         api.IntrospectionQuery,
+		OperationName: "IntrospectionQuery",
+	})
+	if err != nil {
+		return graphQLFailure(err)
+	}
+	if len(resp.Errors) > 0 {
+		for _, e := range resp.Errors {
+			fmt.Fprintf(os.Stderr, "GraphQL error: %s\n", e)
+		}
+		return apiFailure(api.GraphQLErrors(service, resp.StatusCode, resp.Errors))
+	}
+
+	sdl, err := api.PrintSchema(resp.Data)
+	if err != nil {
+		return err
+	}
+
+	if graphqlIntrospect == "-" {
+		fmt.Print(sdl)
+		return nil
+	}
+
+	if err := os.WriteFile(graphqlIntrospect, []byte(sdl), 0644); err != nil {
+		return fmt.Errorf("failed to write schema: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "✓ Wrote %s schema to %s\n", service.Name, graphqlIntrospect)
+	return nil
+}
+
+// graphQLFailure gives HTTP-level API errors the same treatment as
+// errors in the GraphQL response
+func graphQLFailure(err error) error {
+	var apiErr *api.Error
+	if errors.As(err, &apiErr) {
+		return apiFailure(apiErr)
+	}
+	return err
+}
+
+// readGraphQLQuery reads the query from --query, a file, or stdin
+func readGraphQLQuery(args []string) (string, error) {
+	if graphqlQuery != "" {
+		if len(args) > 0 {
+			return "", fmt.Errorf("use either --query or a query file, not both")
+		}
+		return graphqlQuery, nil
+	}
+
+	if len(args) == 0 {
+		return "", fmt.Errorf("no query given. Pass a query file, \"-\" for stdin, or --query")
+	}
+
+	var data []byte
+	var err error
+	if args[0] == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(args[0])
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read query: %w", err)
+	}
+
+	query := strings.TrimSpace(string(data))
+	if query == "" {
+		return "", fmt.Errorf("query is empty")
+	}
+	return query, nil
+}
+
+// parseGraphQLVars parses name=value (string) and name:=value (JSON) pairs
+func parseGraphQLVars(pairs []string) (map[string]interface{}, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+
+	vars := make(map[string]interface{}, len(pairs))
+	for _, pair := range pairs {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid variable %q: expected name=value or name:=json", pair)
+		}
+
+		if rawName, isJSON := strings.CutSuffix(name, ":"); isJSON {
+			var v interface{}
+			if err := json.Unmarshal([]byte(value), &v); err != nil {
+				return nil, fmt.Errorf("invalid JSON for variable %s: %w", rawName, err)
+			}
+			vars[rawName] = v
+			continue
+		}
+
+		vars[name] = value
+	}
+	return vars, nil
+}
@@ -1,9 +1,7 @@
 package cli
 
 import (
-	"bufio"
 	"fmt"
-	"os"
 	"runtime"
 	"strings"
 
@@ -21,12 +19,17 @@ that requires HTTPS (like Slack). You can also run it manually.
 
 The CA certificate will be installed into your system's trust store:
   macOS:   login keychain
-  Linux:   system CA certificates (requires sudo)
+  Linux:   system CA certificates (requires root), plus the NSS
+           databases of Firefox and Chromium (requires certutil)
   Windows: user certificate store
 
+On Linux, --trust-store user only uses the NSS databases, so nothing
+outside your home directory changes and no root access is needed.
+
 Use --force to regenerate the CA certificate if needed.`,
 	Example: `  applink init
-  applink init --force`,
+  applink init --force
+  applink init --trust-store user`,
 	Args:    cobra.NoArgs,
 	RunE:    runInit,
 }
@@ -35,9 +38,15 @@ var forceInit bool
 
 func init() {
 	initCmd.Flags().BoolVarP(&forceInit, "force", "f", false, "Force regeneration of CA certificate")
+	initCmd.Flags().StringVar(&trustStoreFlag, "trust-store", "", trustStoreUsage)
 }
 
 func runInit(cmd *cobra.Command, args []string) error {
+	if err := applyTrustStoreFlag(); err != nil {
+		return err
+	}
+	cmd.SilenceUsage = true // Failures from here on aren't usage errors
+
 	fmt.Println("Initializing applink")
 	fmt.Println(strings.Repeat("─", 50))
 	fmt.Println()
@@ -49,10 +58,16 @@ func runInit(cmd *cobra.Command, args []string) error {
 	}
 
 	if exists && !forceInit {
+		// CAs from older versions can sign for any domain; offer to replace them
+		if caCert, err := certs.LoadCACert(); err == nil && !certs.IsConstrained(caCert) {
+			return migrateUnconstrainedCA()
+		}
+
 		// Check if it's installed
 		if certs.IsCAInstalled() {
 			fmt.Println("✓ applink is already initialized with trusted certificates.")
 			fmt.Println("  Use --force to regenerate the CA certificate.")
+			printTrustGaps()
 			return nil
 		}
 		fmt.Println("CA certificate exists but is not installed in the system trust store.")
@@ -77,20 +92,13 @@ func runInit(cmd *cobra.Command, args []string) error {
 	fmt.Printf("Certificate location: %s\n", certPath)
 	fmt.Println()
 
-	if runtime.GOOS == "linux" {
-		fmt.Println("Note: On Linux, this requires sudo access.")
-		fmt.Println()
-	}
+	printRootNote()
 
-	fmt.Print("Install the CA certificate now? [Y/n] ")
-	reader := bufio.NewReader(os.Stdin)
-	response, err := reader.ReadString('\n')
+	install, err := confirm("Install the CA certificate now?", "Pass --yes to install it.")
 	if err != nil {
-		return fmt.Errorf("failed to read response: %w", err)
+		return err
 	}
-	response = strings.TrimSpace(strings.ToLower(response))
-
-	if response != "" && response != "y" && response != "yes" {
+	if !install {
 		fmt.Println()
 		fmt.Println("CA certificate was generated but not installed.")
 		fmt.Println("You can install it manually or run 'applink init' again.")
@@ -103,7 +111,9 @@ func runInit(cmd *cobra.Command, args []string) error {
 	// Install CA
 	fmt.Println()
 	fmt.Println("Installing CA certificate...")
-	if err := certs.InstallCA(); err != nil {
+	warnings, err := certs.InstallCA()
+	printTrustWarnings(warnings)
+	if err != nil {
 		fmt.Println()
 		fmt.Printf("Failed to install CA: %v\n", err)
 		fmt.Println()
@@ -124,6 +134,37 @@ func runInit(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// migrateUnconstrainedCA offers to replace a CA without name constraints
+func migrateUnconstrainedCA() error {
+	fmt.Println("Your CA certificate was created by an older version of applink and is")
+	fmt.Println("not limited to localhost: anyone who obtains its key could create")
+	fmt.Println("certificates your system trusts for any website.")
+	fmt.Println()
+
+	replace, err := confirm("Replace it with a CA limited to localhost now?", "Pass --yes to replace it, or run: applink certs rotate")
+	if err != nil {
+		return err
+	}
+	if !replace {
+		fmt.Println()
+		fmt.Println("Kept the existing CA. Run 'applink certs rotate' to replace it later.")
+		return nil
+	}
+
+	fmt.Println()
+	printRootNote()
+	removed, warnings, err := certs.RotateCA()
+	printTrustWarnings(warnings)
+	if err != nil {
+		return err
+	}
+	if removed != nil {
+		fmt.Printf("✓ Removed old CA: %s\n", certs.Fingerprint(removed))
+	}
+	fmt.Println("✓ Installed a new CA limited to localhost, 127.0.0.1 and ::1")
+	return nil
+}
+
 func printManualInstructions(certPath string) {
 	switch runtime.GOOS {
 	case "darwin":
@@ -137,6 +178,16 @@ func printManualInstructions(certPath string) {
 		fmt.Println("  RHEL/Fedora:")
 		fmt.Printf("    sudo cp %s /etc/pki/ca-trust/source/anchors/applink-ca.crt\n", certPath)
 		fmt.Println("    sudo update-ca-trust")
+		fmt.Println()
+		fmt.Println("  openSUSE:")
+		fmt.Printf("    sudo cp %s /etc/pki/trust/anchors/applink-ca.crt\n", certPath)
+		fmt.Println("    sudo update-ca-certificates")
+		fmt.Println()
+		fmt.Println("  Arch:")
+		fmt.Printf("    sudo trust anchor --store %s\n", certPath)
+		fmt.Println()
+		fmt.Println("  Firefox and Chromium (per NSS database, e.g. ~/.pki/nssdb):")
+		fmt.Printf("    certutil -d sql:$HOME/.pki/nssdb -A -t C,, -n \"applink Local CA\" -i %s\n", certPath)
 	case "windows":
 		fmt.Println("  Windows:")
 		fmt.Printf("    certutil -addstore -user Root %s\n", certPath)
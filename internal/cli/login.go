@@ -1,17 +1,23 @@
 package cli
 
 import (
-	"bufio"
+	"context"
 	"fmt"
+	"net/url"
 	"os"
-	"runtime"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/jaknapp/applink/internal/api"
 	"github.com/jaknapp/applink/internal/auth"
 	"github.com/jaknapp/applink/internal/certs"
 	"github.com/jaknapp/applink/internal/config"
 	"github.com/jaknapp/applink/internal/storage"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 const defaultCallbackPort = 8888
@@ -24,16 +30,53 @@ var loginCmd = &cobra.Command{
 For OAuth services (slack, notion, linear), this opens your browser
 to complete the authentication flow.
 
-For API key services (honeycomb), this prompts you for the key.`,
+For API key services (honeycomb), this prompts you for the key.
+
+Scopes default to the service's standard set, or to "scopes" for the
+service in ~/.applink/config.json. Logging in again keeps the scopes the
+existing token was granted, so --add-scope requests additional access
+without losing what you have. --scope replaces the whole set.
+
+Slack needs an HTTPS callback. The first Slack login sets up a local CA
+(see 'applink init'); --no-trust skips that and uses a self-signed
+certificate, which the browser will warn about.`,
 	Example: `  applink login slack
   applink login notion
-  applink login honeycomb`,
+  applink login honeycomb
+  applink login slack --add-scope reactions:write
+  applink login slack --scope chat:write
+  applink login slack --no-trust`,
 	Args: cobra.ExactArgs(1),
 	RunE: runLogin,
 }
 
+var (
+	loginPort         int
+	loginPortSet      bool // --port was given; 0 is a valid choice
+	loginRedirectHost string
+	loginTimeout      time.Duration
+	loginScopes       []string
+	loginAddScopes    []string
+	loginRemoveScopes []string
+	loginBotScopes    []string
+	loginNoTrust      bool
+)
+
+func init() {
+	loginCmd.Flags().IntVarP(&loginPort, "port", "p", 0, "Port for the OAuth callback server (must match a registered redirect URI; 0 picks any free port)")
+	loginCmd.Flags().StringVar(&loginRedirectHost, "redirect-host", "", "Redirect URI host: localhost, 127.0.0.1 or [::1]")
+	loginCmd.Flags().DurationVar(&loginTimeout, "timeout", 0, "How long to wait for the browser to complete the login (default 5m)")
+	loginCmd.Flags().StringSliceVar(&loginScopes, "scope", nil, "Scopes to request instead of the defaults (repeatable or comma-separated)")
+	loginCmd.Flags().StringSliceVar(&loginAddScopes, "add-scope", nil, "Scope to request in addition to the defaults and existing scopes")
+	loginCmd.Flags().StringSliceVar(&loginRemoveScopes, "remove-scope", nil, "Scope to leave out of the request")
+	loginCmd.Flags().StringSliceVar(&loginBotScopes, "bot-scope", nil, "Bot token scopes to request as well (Slack)")
+	loginCmd.Flags().StringVar(&trustStoreFlag, "trust-store", "", trustStoreUsage)
+	loginCmd.Flags().BoolVar(&loginNoTrust, "no-trust", false, "Don't set up the local CA; serve HTTPS callbacks with a self-signed certificate (the browser will warn)")
+}
+
 func runLogin(cmd *cobra.Command, args []string) error {
 	serviceName := args[0]
+	loginPortSet = cmd.Flags().Changed("port")
 
 	// Get service definition
 	service, err := config.GetService(serviceName)
@@ -41,14 +84,32 @@ func runLogin(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	scopeFlags := len(loginScopes) > 0 || len(loginAddScopes) > 0 || len(loginRemoveScopes) > 0 || len(loginBotScopes) > 0
+	if scopeFlags && service.AuthType != config.AuthTypeOAuth {
+		return fmt.Errorf("%s uses an API key; scopes are set when the key is created", service.Name)
+	}
+	if len(loginBotScopes) > 0 && !auth.SupportsBotToken(service) {
+		return fmt.Errorf("%s does not issue bot tokens", service.Name)
+	}
+
 	fmt.Printf("Authenticating with %s...\n", service.Name)
+	cmd.SilenceUsage = true // Failures from here on aren't usage errors
 
 	var token *auth.Token
 	switch service.AuthType {
 	case config.AuthTypeOAuth:
-		token, err = doOAuthLogin(service, serviceName)
+		// Ctrl-C shuts the callback server down cleanly
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		token, err = doOAuthLogin(ctx, service, serviceName)
 	case config.AuthTypeAPIKey:
+		if nonInteractive() && term.IsTerminal(int(syscall.Stdin)) {
+			return inputRequired(service.Name+" API key", "Pipe the key to stdin: printf %s \"$KEY\" | applink login "+serviceName)
+		}
 		token, err = auth.PromptAPIKey(service)
+		if err == nil {
+			identifyAccount(cmd.Context(), service, token)
+		}
 	default:
 		return fmt.Errorf("unsupported auth type: %s", service.AuthType)
 	}
@@ -62,11 +123,55 @@ func runLogin(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to store token: %w", err)
 	}
 
-	fmt.Printf("✓ Successfully authenticated with %s\n", service.Name)
+	if account := accountName(token); account != "" {
+		fmt.Printf("✓ Successfully authenticated with %s as %s\n", service.Name, account)
+	} else {
+		fmt.Printf("✓ Successfully authenticated with %s\n", service.Name)
+	}
+	if storage.HasEnvToken(serviceName) {
+		fmt.Printf("  Note: APPLINK_%s_TOKEN is set and takes precedence over the stored token.\n", strings.ToUpper(serviceName))
+	}
 	return nil
 }
 
-func doOAuthLogin(service *config.Service, serviceName string) (*auth.Token, error) {
+// identifyLookupTimeout bounds the identity lookup after login
+const identifyLookupTimeout = 10 * time.Second
+
+// identifyAccount records who the new token belongs to. Failure only
+// costs the account details, so it is a warning rather than an error.
+func identifyAccount(ctx context.Context, service *config.Service, token *auth.Token) {
+	ctx, cancel := context.WithTimeout(ctx, identifyLookupTimeout)
+	defer cancel()
+
+	// A Slack install may only have granted a bot token
+	lookupToken, err := token.ForKind("")
+	if err != nil {
+		return
+	}
+
+	identity, err := api.LookupIdentity(ctx, newHTTPClient(), service, lookupToken)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not look up the %s account: %v\n", service.Name, err)
+		return
+	}
+	identity.Apply(token)
+	debugLog("Identity: user=%q (%s) workspace=%q (%s)", token.User, token.UserID, token.Workspace, token.WorkspaceID)
+}
+
+// accountName describes the account a token belongs to, e.g.
+// "alice@example.com @ Acme"
+func accountName(token *auth.Token) string {
+	switch {
+	case token.User != "" && token.Workspace != "":
+		return token.User + " @ " + token.Workspace
+	case token.User != "":
+		return token.User
+	default:
+		return token.Workspace
+	}
+}
+
+func doOAuthLogin(ctx context.Context, service *config.Service, serviceName string) (*auth.Token, error) {
 	// Get credentials (env vars → keychain)
 	creds, err := storage.GetCredentials(serviceName)
 	if err != nil {
@@ -92,19 +197,200 @@ func doOAuthLogin(service *config.Service, serviceName string) (*auth.Token, err
 		ClientSecret: creds.ClientSecret,
 	}
 
-	return auth.DoOAuthFlow(service, clientCreds, defaultCallbackPort)
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	timeout, err := callbackTimeout(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	successRedirect, err := successRedirectURL(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	configDir, err := config.GetConfigDir()
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep what the current token was granted (incremental authorization)
+	existing, _ := storage.GetToken(serviceName)
+
+	opts := auth.FlowOptions{
+		Ports:              callbackPorts(service, settings),
+		RedirectHost:       redirectHost(service, settings),
+		Timeout:            timeout,
+		Scopes:             requestedScopes(service, settings, existing),
+		BotScopes:          requestedBotScopes(service, settings, existing),
+		TemplateDir:        filepath.Join(configDir, "templates"),
+		SuccessRedirectURL: successRedirect,
+		SelfSignedCert:     loginNoTrust,
+		Identify: func(ctx context.Context, token *auth.Token) {
+			identifyAccount(ctx, service, token)
+		},
+	}
+	debugLog("Callback ports: %v, redirect host: %q, timeout: %s", opts.Ports, opts.RedirectHost, opts.Timeout)
+	debugLog("Requesting scopes: %v, bot scopes: %v", opts.Scopes, opts.BotScopes)
+
+	return auth.DoOAuthFlow(ctx, service, clientCreds, opts)
+}
+
+// callbackTimeout returns how long to wait for the OAuth callback.
+// Priority: --timeout → config → default.
+func callbackTimeout(settings *config.Settings) (time.Duration, error) {
+	if loginTimeout > 0 {
+		return loginTimeout, nil
+	}
+	if settings.CallbackTimeout == "" {
+		return auth.DefaultCallbackTimeout, nil
+	}
+	timeout, err := time.ParseDuration(settings.CallbackTimeout)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid callback_timeout %q in config: expected a duration like \"10m\"", settings.CallbackTimeout)
+	}
+	return timeout, nil
+}
+
+// successRedirectURL returns the configured post-login redirect, if any
+func successRedirectURL(settings *config.Settings) (string, error) {
+	if settings.SuccessRedirectURL == "" {
+		return "", nil
+	}
+	u, err := url.Parse(settings.SuccessRedirectURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid success_redirect_url %q in config: expected an http(s) URL", settings.SuccessRedirectURL)
+	}
+	return u.String(), nil
+}
+
+// requestedScopes returns the scopes to request at login.
+// Base set: --scope → per-service config → service definition. Unless
+// --scope is given, scopes granted to the existing token are kept. Then
+// --add-scope and --remove-scope are applied.
+func requestedScopes(service *config.Service, settings *config.Settings, existing *storage.Token) []string {
+	var scopes []string
+	switch {
+	case len(loginScopes) > 0:
+		scopes = append(scopes, loginScopes...)
+	case len(settings.ServiceSettings(service.ID).Scopes) > 0:
+		scopes = append(scopes, settings.ServiceSettings(service.ID).Scopes...)
+	default:
+		scopes = append(scopes, service.Scopes...)
+	}
+
+	if len(loginScopes) == 0 && existing != nil {
+		// Providers that don't report granted scopes got what was requested
+		if granted := splitScopes(existing.Scope); len(granted) > 0 {
+			scopes = append(scopes, granted...)
+		} else {
+			scopes = append(scopes, existing.RequestedScopes...)
+		}
+	}
+	scopes = append(scopes, loginAddScopes...)
+
+	return uniqueScopes(scopes, loginRemoveScopes)
+}
+
+// uniqueScopes drops duplicates (keeping the first occurrence), blanks and
+// the scopes in remove
+func uniqueScopes(scopes, remove []string) []string {
+	skip := make(map[string]bool, len(scopes)+len(remove))
+	for _, s := range remove {
+		skip[s] = true
+	}
+
+	result := []string{}
+	for _, s := range scopes {
+		s = strings.TrimSpace(s)
+		if s == "" || skip[s] {
+			continue
+		}
+		skip[s] = true
+		result = append(result, s)
+	}
+	return result
+}
+
+// requestedBotScopes returns the bot token scopes to request at login.
+// Base set: --bot-scope → per-service config → service definition. Unless
+// --bot-scope is given, scopes granted to the existing bot token are kept.
+func requestedBotScopes(service *config.Service, settings *config.Settings, existing *storage.Token) []string {
+	if !auth.SupportsBotToken(service) {
+		return nil
+	}
+
+	var scopes []string
+	switch {
+	case len(loginBotScopes) > 0:
+		scopes = append(scopes, loginBotScopes...)
+	case len(settings.ServiceSettings(service.ID).BotScopes) > 0:
+		scopes = append(scopes, settings.ServiceSettings(service.ID).BotScopes...)
+	default:
+		scopes = append(scopes, service.BotScopes...)
+	}
+
+	if len(loginBotScopes) == 0 && existing != nil && existing.Bot != nil {
+		scopes = append(scopes, splitScopes(existing.Bot.Scope)...)
+	}
+
+	return uniqueScopes(scopes, nil)
+}
+
+// callbackPorts returns the ports to try for the OAuth callback server.
+// Priority: --port → per-service config → global config → service
+// definition → default. Port 0 means any free port, so an explicit
+// --port 0 is told apart from an unset flag.
+func callbackPorts(service *config.Service, settings *config.Settings) []int {
+	if loginPortSet {
+		return []int{loginPort}
+	}
+
+	serviceSettings := settings.ServiceSettings(service.ID)
+
+	switch {
+	case serviceSettings.AnyCallbackPort || service.AnyCallbackPort:
+		return []int{0}
+	case len(serviceSettings.CallbackPorts) > 0:
+		return serviceSettings.CallbackPorts
+	case settings.CallbackPort != 0:
+		return []int{settings.CallbackPort}
+	case len(service.CallbackPorts) > 0:
+		return service.CallbackPorts
+	default:
+		return []int{defaultCallbackPort}
+	}
+}
+
+// redirectHost returns the redirect URI host.
+// Priority: --redirect-host → per-service config → service definition.
+func redirectHost(service *config.Service, settings *config.Settings) string {
+	if loginRedirectHost != "" {
+		return loginRedirectHost
+	}
+	if host := settings.ServiceSettings(service.ID).RedirectHost; host != "" {
+		return host
+	}
+	return service.RedirectHost
 }
 
 // ensureCertsInitialized checks if certificates are set up and initializes them if needed
 func ensureCertsInitialized(serviceName string) error {
 	// Only needed for services that require HTTPS (like Slack)
-	if serviceName != "slack" {
+	if serviceName != "slack" || loginNoTrust {
 		return nil
 	}
+	if err := applyTrustStoreFlag(); err != nil {
+		return err
+	}
 
 	// Check if CA exists and is installed
 	caExists, _ := certs.CAExists()
 	if caExists && certs.IsCAInstalled() {
+		printTrustGaps()
 		return nil // Already set up
 	}
 
@@ -119,20 +405,13 @@ func ensureCertsInitialized(serviceName string) error {
 	fmt.Println("authority into your system's trust store.")
 	fmt.Println()
 
-	if runtime.GOOS == "linux" {
-		fmt.Println("Note: This requires sudo access on Linux.")
-		fmt.Println()
-	}
+	printRootNote()
 
-	fmt.Print("Continue? [Y/n] ")
-	reader := bufio.NewReader(os.Stdin)
-	response, err := reader.ReadString('\n')
+	install, err := confirm("Continue?", "Pass --yes to install the CA, or --no-trust to use a self-signed certificate.")
 	if err != nil {
-		return fmt.Errorf("failed to read response: %w", err)
+		return err
 	}
-	response = strings.TrimSpace(strings.ToLower(response))
-
-	if response != "" && response != "y" && response != "yes" {
+	if !install {
 		fmt.Println()
 		fmt.Println("Certificate setup skipped. Your browser will show a security warning.")
 		fmt.Println("Click 'Advanced' → 'Proceed to localhost' to continue.")
@@ -155,21 +434,14 @@ func ensureCertsInitialized(serviceName string) error {
 
 	// Install CA
 	fmt.Println("Installing to system trust store...")
-	if err := certs.InstallCA(); err != nil {
+	warnings, err := certs.InstallCA()
+	printTrustWarnings(warnings)
+	if err != nil {
 		certPath, _ := certs.GetCACertPath()
 		fmt.Printf("Warning: Failed to install CA: %v\n", err)
 		fmt.Println()
 		fmt.Println("You can install it manually:")
-		switch runtime.GOOS {
-		case "darwin":
-			fmt.Printf("  security add-trusted-cert -r trustRoot -k login.keychain %s\n", certPath)
-		case "linux":
-			fmt.Printf("  sudo cp %s /usr/local/share/ca-certificates/applink-ca.crt\n", certPath)
-			fmt.Println("  sudo update-ca-certificates")
-		case "windows":
-			fmt.Printf("  certutil -addstore -user Root %s\n", certPath)
-		}
-		fmt.Println()
+		printManualInstructions(certPath)
 		fmt.Println("Continuing with browser warning...")
 		return nil
 	}
@@ -2,6 +2,7 @@ package cli
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/jaknapp/applink/internal/config"
 	"github.com/jaknapp/applink/internal/storage"
@@ -9,16 +10,35 @@ import (
 )
 
 var logoutCmd = &cobra.Command{
-	Use:   "logout <service>",
+	Use:   "logout [service]",
 	Short: "Remove credentials for a service",
-	Long:  `Remove stored credentials for a service from your system keychain.`,
+	Long: `Remove stored credentials for a service from your system keychain.
+
+--all removes every token and OAuth client credential applink has stored,
+including those of services it no longer knows (see 'applink secrets list').`,
 	Example: `  applink logout slack
-  applink logout notion`,
-	Args: cobra.ExactArgs(1),
+  applink logout notion
+  applink logout --all`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: runLogout,
 }
 
+var logoutAll bool
+
+func init() {
+	logoutCmd.Flags().BoolVar(&logoutAll, "all", false, "Remove all tokens and client credentials")
+}
+
 func runLogout(cmd *cobra.Command, args []string) error {
+	if logoutAll {
+		if len(args) > 0 {
+			return fmt.Errorf("pass a service or --all, not both")
+		}
+		return logoutEverything(cmd)
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("pass a service, or --all to log out of everything")
+	}
 	serviceName := args[0]
 
 	// Verify service exists
@@ -31,5 +51,55 @@ func runLogout(cmd *cobra.Command, args []string) error {
 	}
 
 	fmt.Printf("✓ Removed credentials for %s\n", serviceName)
+	if storage.HasEnvToken(serviceName) {
+		fmt.Printf("  Note: APPLINK_%s_TOKEN is still set and will be used until you unset it.\n", strings.ToUpper(serviceName))
+	}
+	return nil
+}
+
+// logoutEverything deletes every stored token and client credential.
+// Internal secrets such as the CA key are kept; see 'applink certs uninstall'.
+func logoutEverything(cmd *cobra.Command) error {
+	cmd.SilenceUsage = true
+
+	entries, err := storedEntries()
+	if err != nil {
+		return err
+	}
+
+	var targets []storedEntry
+	for _, e := range entries {
+		if e.Kind != storage.EntrySecret {
+			targets = append(targets, e)
+		}
+	}
+	if len(targets) == 0 {
+		fmt.Println("Nothing to remove.")
+		return nil
+	}
+
+	for _, e := range targets {
+		fmt.Printf("  %s %s\n", e.Kind, e.Name)
+	}
+	ok, err := confirmNo(fmt.Sprintf("Remove these %d entries from the keychain?", len(targets)), "Pass --yes to remove them.")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+
+	for _, e := range targets {
+		if err := storage.DeleteEntry(e.Kind, e.Name); err != nil {
+			return fmt.Errorf("failed to remove %s %s: %w", e.Kind, e.Name, err)
+		}
+	}
+	fmt.Printf("✓ Removed %d tokens and credentials\n", len(targets))
+
+	for _, service := range config.AllServices() {
+		if storage.HasEnvToken(service.ID) {
+			fmt.Printf("  Note: APPLINK_%s_TOKEN is still set and will be used until you unset it.\n", strings.ToUpper(service.ID))
+		}
+	}
 	return nil
 }
@@ -2,7 +2,9 @@ package cli
 
 import (
 	"fmt"
+	"sort"
 
+	"github.com/jaknapp/applink/internal/auth"
 	"github.com/jaknapp/applink/internal/config"
 	"github.com/jaknapp/applink/internal/mcp"
 	"github.com/jaknapp/applink/internal/storage"
@@ -40,10 +42,21 @@ var mcpRemoveCmd = &cobra.Command{
 var mcpListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List configured MCP servers",
-	RunE:  runMCPList,
+	Long: `List the MCP servers configured in Cursor.
+
+With --output json or yaml, each server's command and arguments are
+included. Environment variable values (which hold tokens) are not printed,
+only their names.`,
+	RunE:        runMCPList,
+	Annotations: outputCommand,
 }
 
+var mcpTokenKind string
+
 func init() {
+	mcpInstallCmd.Flags().StringVar(&mcpTokenKind, "token-kind", "", "Token to configure for services with several: user or bot")
+	mcpAddCmd.Flags().StringVar(&mcpTokenKind, "token-kind", "", "Token to configure for services with several: user or bot")
+
 	mcpCmd.AddCommand(mcpInstallCmd)
 	mcpCmd.AddCommand(mcpAddCmd)
 	mcpCmd.AddCommand(mcpRemoveCmd)
@@ -74,7 +87,12 @@ func runMCPInstall(cmd *cobra.Command, args []string) error {
 	fmt.Printf("→ Found tokens for: %v\n", authenticated)
 
 	for _, serviceName := range authenticated {
-		if err := mcp.AddService(serviceName); err != nil {
+		// --token-kind only applies to services that issue several kinds
+		kind := mcpTokenKind
+		if service, _ := config.GetService(serviceName); !auth.SupportsBotToken(service) {
+			kind = ""
+		}
+		if err := mcp.AddService(serviceName, kind); err != nil {
 			fmt.Printf("  ✗ Failed to add %s: %v\n", serviceName, err)
 		} else {
 			fmt.Printf("  ✓ Added %s\n", serviceName)
@@ -97,13 +115,16 @@ func runMCPAdd(cmd *cobra.Command, args []string) error {
 	if service.MCPPackage == "" {
 		return fmt.Errorf("%s does not have MCP server support", serviceName)
 	}
+	if mcpTokenKind == "bot" && !auth.SupportsBotToken(service) {
+		return fmt.Errorf("%s has no bot token", serviceName)
+	}
 
 	token, err := storage.GetToken(serviceName)
 	if err != nil || token == nil {
 		return fmt.Errorf("not authenticated with %s. Run: applink login %s", serviceName, serviceName)
 	}
 
-	if err := mcp.AddService(serviceName); err != nil {
+	if err := mcp.AddService(serviceName, mcpTokenKind); err != nil {
 		return fmt.Errorf("failed to add MCP server: %w", err)
 	}
 
@@ -127,6 +148,19 @@ func runMCPRemove(cmd *cobra.Command, args []string) error {
 }
 
 func runMCPList(cmd *cobra.Command, args []string) error {
+	format, err := structuredFormat()
+	if err != nil {
+		return err
+	}
+
+	if format != "" {
+		cfg, err := mcp.LoadCursorConfig()
+		if err != nil {
+			return fmt.Errorf("failed to list MCP servers: %w", err)
+		}
+		return writeStructured(mcpListReport(cfg), format)
+	}
+
 	servers, err := mcp.ListServers()
 	if err != nil {
 		return fmt.Errorf("failed to list MCP servers: %w", err)
@@ -143,3 +177,51 @@ func runMCPList(cmd *cobra.Command, args []string) error {
 	}
 	return nil
 }
+
+// mcpListOutput is the schema of `applink mcp list --output json|yaml`
+type mcpListOutput struct {
+	Servers []mcpServerEntry `json:"servers"`
+}
+
+type mcpServerEntry struct {
+	Name    string   `json:"name"`
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+	Env     []string `json:"env"`     // Variable names only
+	Managed bool     `json:"managed"` // Name matches an applink service
+}
+
+func mcpListReport(cfg *mcp.CursorConfig) *mcpListOutput {
+	names := make([]string, 0, len(cfg.MCPServers))
+	for name := range cfg.MCPServers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	report := &mcpListOutput{Servers: make([]mcpServerEntry, 0, len(names))}
+	for _, name := range names {
+		server := cfg.MCPServers[name]
+		entry := mcpServerEntry{
+			Name:    name,
+			Args:    []string{},
+			Env:     []string{},
+			Managed: isServiceName(name),
+		}
+		if server != nil {
+			entry.Command = server.Command
+			entry.Args = append(entry.Args, server.Args...)
+			for key := range server.Env {
+				entry.Env = append(entry.Env, key)
+			}
+			sort.Strings(entry.Env)
+		}
+		report.Servers = append(report.Servers, entry)
+	}
+
+	return report
+}
+
+func isServiceName(name string) bool {
+	_, err := config.GetService(name)
+	return err == nil
+}
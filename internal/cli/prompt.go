@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+
+	"golang.org/x/term"
+)
+
+// nonInteractiveEnv disables prompts like --no-input
+const nonInteractiveEnv = "APPLINK_NONINTERACTIVE"
+
+var (
+	assumeYes bool // --yes: answer yes to confirmations
+	noInput   bool // --no-input: never read from the terminal
+)
+
+// stdinReader is shared by all prompts, so input buffered by one prompt
+// isn't lost to the next
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// nonInteractive reports whether prompts are disabled by --no-input or
+// APPLINK_NONINTERACTIVE
+func nonInteractive() bool {
+	if noInput {
+		return true
+	}
+	value := strings.ToLower(os.Getenv(nonInteractiveEnv))
+	return value != "" && value != "0" && value != "false" && value != "no"
+}
+
+// inputRequired is the error for a prompt that can't be shown. hint says
+// how to provide the answer without a prompt.
+func inputRequired(what, hint string) error {
+	return fmt.Errorf("%s requires input, but prompts are disabled (--no-input or %s)\n%s", what, nonInteractiveEnv, hint)
+}
+
+// confirm asks a yes/no question that defaults to yes. --yes answers it
+// without reading input; in non-interactive mode it fails with hint.
+func confirm(question, hint string) (bool, error) {
+	if assumeYes {
+		fmt.Printf("%s [Y/n] y (--yes)\n", question)
+		return true, nil
+	}
+	if nonInteractive() {
+		return false, inputRequired(fmt.Sprintf("%q", question), hint)
+	}
+
+	fmt.Printf("%s [Y/n] ", question)
+	response, err := stdinReader.ReadString('\n')
+	if err != nil {
+		return false, fmt.Errorf("failed to read response: %w", err)
+	}
+	response = strings.TrimSpace(strings.ToLower(response))
+	return response == "" || response == "y" || response == "yes", nil
+}
+
+// confirmNo is confirm for questions that default to no
+func confirmNo(question, hint string) (bool, error) {
+	if assumeYes {
+		fmt.Printf("%s [y/N] y (--yes)\n", question)
+		return true, nil
+	}
+	if nonInteractive() {
+		return false, inputRequired(fmt.Sprintf("%q", question), hint)
+	}
+
+	fmt.Printf("%s [y/N] ", question)
+	response, err := stdinReader.ReadString('\n')
+	if err != nil {
+		return false, fmt.Errorf("failed to read response: %w", err)
+	}
+	response = strings.TrimSpace(strings.ToLower(response))
+	return response == "y" || response == "yes", nil
+}
+
+// promptLine reads a line of visible input
+func promptLine(out io.Writer, label string) (string, error) {
+	fmt.Fprint(out, label)
+	line, err := stdinReader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// promptSecret reads a line without echoing it if stdin is a terminal
+func promptSecret(out io.Writer, label string) (string, error) {
+	if !term.IsTerminal(int(syscall.Stdin)) {
+		return promptLine(out, label)
+	}
+
+	fmt.Fprint(out, label)
+	secret, err := term.ReadPassword(int(syscall.Stdin))
+	fmt.Fprintln(out) // New line after hidden input
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(secret)), nil
+}
+
+// readSecretStdin reads a secret piped to stdin, e.g. by --client-secret-stdin
+func readSecretStdin() (string, error) {
+	if term.IsTerminal(int(syscall.Stdin)) && nonInteractive() {
+		return "", fmt.Errorf("nothing piped to stdin; e.g. printf %%s \"$SECRET\" | applink ...")
+	}
+	data, err := io.ReadAll(stdinReader)
+	if err != nil {
+		return "", fmt.Errorf("failed to read stdin: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -6,29 +6,71 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"os"
 	"strings"
 
+	"github.com/jaknapp/applink/internal/api"
+	"github.com/jaknapp/applink/internal/cassette"
 	"github.com/jaknapp/applink/internal/config"
+	"github.com/jaknapp/applink/internal/output"
 	"github.com/jaknapp/applink/internal/storage"
 	"github.com/spf13/cobra"
 )
 
-var requestData string
+var (
+	requestData      string
+	requestFilter    string
+	requestSilent    bool
+	requestRecord    string
+	requestReplay    string
+	requestTokenKind string
+)
 
 var requestCmd = &cobra.Command{
 	Use:   "request <service> <method> <path>",
 	Short: "Send an authenticated API request",
 	Long: `Send an authenticated HTTP request to a service's API.
-The request will automatically include the appropriate authentication headers.`,
+The request will automatically include the appropriate authentication headers.
+
+JSON responses can be narrowed with --filter, which supports a subset of
+jq and JSONPath: .field, .[0], .[], $..field, pipes, length, keys and
+select(.field == "value"). Filters using [], .*, .. or select() always
+print an array (one value per line with --output raw), even for zero or
+one results; other filters print a single value.
+
+Use --record to save request/response pairs to a cassette file (with
+the Authorization header redacted), and --replay or APPLINK_REPLAY to
+answer requests from it without network access or a stored token.
+Replayed requests are matched on method, path, query and body.
+
+For GraphQL APIs, 'applink graphql' is usually more convenient.
+
+Exit codes:
+  0  success (2xx/3xx)
+  1  request could not be sent or other error
+  4  the API returned a 4xx status or reported an error in the body
+     (e.g. Slack's "ok": false, Notion's "object": "error", GraphQL errors)
+  5  the API returned a 5xx status`,
 	Example: `  applink request slack GET /api/conversations.list
   applink request notion POST /v1/search --data '{"query": "meeting notes"}'
-  applink request linear POST /graphql --data '{"query": "{ viewer { id } }"}'`,
-	Args: cobra.ExactArgs(3),
-	RunE: runRequest,
+  applink request linear POST /graphql --data '{"query": "{ viewer { id } }"}'
+  applink request slack GET /api/conversations.list --filter '.channels[] | .name' --output raw
+  applink request slack GET /api/conversations.list --filter '.channels' --output table
+  applink request notion GET /v1/users/me --silent && echo "token works"
+  applink request slack GET /api/auth.test --record slack.cassette.json
+  APPLINK_REPLAY=slack.cassette.json applink request slack GET /api/auth.test`,
+	Args:        cobra.ExactArgs(3),
+	RunE:        runRequest,
+	Annotations: outputCommand,
 }
 
 func init() {
 	requestCmd.Flags().StringVarP(&requestData, "data", "d", "", "Request body (JSON)")
+	requestCmd.Flags().StringVarP(&requestFilter, "filter", "f", "", "Filter the JSON response (jq/JSONPath subset, e.g. '.channels[].name')")
+	requestCmd.Flags().BoolVarP(&requestSilent, "silent", "s", false, "Print nothing; only report the result through the exit code")
+	requestCmd.Flags().StringVar(&requestRecord, "record", "", "Record the request and response to a cassette file")
+	requestCmd.Flags().StringVar(&requestReplay, "replay", "", "Serve the response from a cassette file instead of the API (or set APPLINK_REPLAY)")
+	requestCmd.Flags().StringVar(&requestTokenKind, "token-kind", "", "Token to use for services with several: user or bot")
 }
 
 func runRequest(cmd *cobra.Command, args []string) error {
@@ -36,17 +78,45 @@ func runRequest(cmd *cobra.Command, args []string) error {
 	method := strings.ToUpper(args[1])
 	path := args[2]
 
+	if requestSilent {
+		cmd.SilenceErrors = true
+		cmd.SilenceUsage = true
+	}
+
+	format, err := responseFormat()
+	if err != nil {
+		return err
+	}
+	var filter *output.Filter
+	if requestFilter != "" {
+		if filter, err = output.ParseFilter(requestFilter); err != nil {
+			return err
+		}
+	}
+
 	service, err := config.GetService(serviceName)
 	if err != nil {
 		return err
 	}
+	cmd.SilenceUsage = true // Failures from here on aren't usage errors
 
-	token, err := storage.GetToken(serviceName)
+	transport, replaying, err := requestTransport()
 	if err != nil {
+		return err
+	}
+
+	token, err := storage.GetToken(serviceName)
+	if err != nil && !replaying {
 		return fmt.Errorf("failed to get token: %w", err)
 	}
 	if token == nil {
-		return fmt.Errorf("not authenticated with %s. Run: applink login %s", serviceName, serviceName)
+		// Replayed responses don't need real credentials
+		if !replaying {
+			return fmt.Errorf("not authenticated with %s. Run: applink login %s", serviceName, serviceName)
+		}
+		token = &storage.Token{}
+	} else if token, err = selectToken(serviceName, token, requestTokenKind); err != nil {
+		return err
 	}
 
 	// Build URL
@@ -58,22 +128,12 @@ func runRequest(cmd *cobra.Command, args []string) error {
 		body = bytes.NewBufferString(requestData)
 	}
 
-	req, err := http.NewRequest(method, url, body)
+	// Create request with auth headers based on service type
+	req, err := api.NewRequest(service, token, method, url, body)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 
-	// Add auth header based on service type
-	switch serviceName {
-	case "notion":
-		req.Header.Set("Authorization", "Bearer "+token.AccessToken)
-		req.Header.Set("Notion-Version", "2022-06-28")
-	case "linear":
-		req.Header.Set("Authorization", token.AccessToken)
-	default:
-		req.Header.Set("Authorization", "Bearer "+token.AccessToken)
-	}
-
 	if requestData != "" {
 		req.Header.Set("Content-Type", "application/json")
 	}
@@ -81,7 +141,7 @@ func runRequest(cmd *cobra.Command, args []string) error {
 	debugLog("Request: %s %s", method, url)
 
 	// Send request
-	client := &http.Client{}
+	client := &http.Client{Transport: transport}
 	resp, err := client.Do(req)
 	if err != nil {
 		return fmt.Errorf("request failed: %w", err)
@@ -94,18 +154,121 @@ func runRequest(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to read response: %w", err)
 	}
 
-	// Pretty print JSON
-	var prettyJSON bytes.Buffer
-	if err := json.Indent(&prettyJSON, respBody, "", "  "); err != nil {
+	if !requestSilent {
+		if err := printResponse(respBody, format, filter); err != nil {
+			return err
+		}
+	}
+
+	// Slack and others report failures inside HTTP 200 responses
+	if apiErr := api.CheckResponse(service, resp.StatusCode, respBody); apiErr != nil {
+		return apiFailure(apiErr)
+	}
+
+	return nil
+}
+
+// apiFailure turns an API error into a command error with the matching
+// exit code, suggesting a new login if the token stopped working
+func apiFailure(apiErr *api.Error) error {
+	var err error = apiErr
+	if apiErr.IsAuthError() {
+		err = fmt.Errorf("%w\n\nThe %s token is invalid, expired or revoked. Run: applink login %s",
+			apiErr, apiErr.Service, apiErr.Service)
+	}
+	return &exitError{code: apiErrorExitCode(apiErr.StatusCode), err: err}
+}
+
+// printResponse writes the response body in the selected output format,
+// after applying --filter if given
+func printResponse(respBody []byte, format output.Format, filter *output.Filter) error {
+	if filter == nil {
+		if format == output.FormatRaw {
+			fmt.Println(string(respBody))
+			return nil
+		}
+
+		// Pretty print JSON, keeping the key order of the response
+		if format == output.FormatJSON {
+			var prettyJSON bytes.Buffer
+			if err := json.Indent(&prettyJSON, respBody, "", "  "); err != nil {
+				// Not JSON, print raw
+				fmt.Println(string(respBody))
+			} else {
+				fmt.Println(prettyJSON.String())
+			}
+			return nil
+		}
+	}
+
+	value, err := output.Decode(respBody)
+	if err != nil {
+		if filter != nil {
+			return fmt.Errorf("--filter requires a JSON response")
+		}
 		// Not JSON, print raw
 		fmt.Println(string(respBody))
-	} else {
-		fmt.Println(prettyJSON.String())
+		return nil
+	}
+
+	if filter != nil {
+		results, err := filter.Apply(value)
+		if err != nil {
+			return fmt.Errorf("filter failed: %w", err)
+		}
+
+		// Iterating filters always yield an array, even with zero or one
+		// results, so the output shape doesn't depend on the data
+		if filter.Iterates() {
+			if results == nil {
+				results = []interface{}{}
+			}
+			value = results
+		} else {
+			value = results[0]
+		}
 	}
 
-	if resp.StatusCode >= 400 {
-		return fmt.Errorf("request returned status %d", resp.StatusCode)
+	return output.Write(os.Stdout, value, format)
+}
+
+// requestTransport returns the HTTP transport for --record/--replay, and
+// whether responses come from a cassette
+func requestTransport() (http.RoundTripper, bool, error) {
+	replayPath := requestReplay
+	if replayPath == "" {
+		replayPath = os.Getenv("APPLINK_REPLAY")
 	}
 
-	return nil
+	if replayPath != "" && requestRecord != "" {
+		return nil, false, fmt.Errorf("--record and --replay cannot be used together")
+	}
+
+	switch {
+	case replayPath != "":
+		c, err := cassette.Load(replayPath)
+		if err != nil {
+			return nil, false, err
+		}
+		debugLog("Replaying responses from %s", replayPath)
+		return httpTransport(&cassette.Player{Cassette: c}), true, nil
+	case requestRecord != "":
+		c, err := cassette.Load(requestRecord)
+		if err != nil {
+			return nil, false, err
+		}
+		debugLog("Recording to %s", requestRecord)
+		return httpTransport(&cassette.Recorder{Cassette: c, Base: http.DefaultTransport}), false, nil
+	default:
+		return httpTransport(http.DefaultTransport), false, nil
+	}
+}
+
+// apiErrorExitCode maps the HTTP status of a failed API call to the
+// process exit code
+func apiErrorExitCode(status int) int {
+	if status >= 500 {
+		return 5
+	}
+	return 4
 }
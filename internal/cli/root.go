@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -8,9 +9,11 @@ import (
 )
 
 var (
-	debug   bool
-	version = "dev"
-	commit  = "none"
+	debug        bool
+	harFile      string
+	outputFormat string
+	version      = "dev"
+	commit       = "none"
 )
 
 // SetVersion sets the version info (called from main with ldflags values)
@@ -28,14 +31,54 @@ var rootCmd = &cobra.Command{
 
 It handles OAuth flows, stores credentials securely in your system keychain,
 and automatically configures MCP servers.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkOutputFlag(cmd); err != nil {
+			return err
+		}
+		setupHTTPLogging()
+		setupCerts()
+		return nil
+	},
 }
 
 func Execute() error {
-	return rootCmd.Execute()
+	err := rootCmd.Execute()
+	flushHTTPLog()
+	return err
+}
+
+// ExitCode returns the process exit code for an error returned by Execute
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+	return 1
+}
+
+// exitError carries a specific process exit code for a failed command
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
 }
 
 func init() {
-	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "Enable debug output")
+	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "Enable debug output (includes HTTP traffic in HAR format)")
+	rootCmd.PersistentFlags().StringVar(&harFile, "har", "", "Save HTTP traffic to a HAR 1.2 file (secrets redacted)")
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "output", "", "Machine-readable output: json or yaml (request also takes table and raw)")
+	rootCmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false, "Answer yes to confirmation prompts")
+	rootCmd.PersistentFlags().BoolVar(&noInput, "no-input", false, "Never prompt; fail if input is needed (also APPLINK_NONINTERACTIVE=1)")
 
 	rootCmd.AddCommand(initCmd)
 	rootCmd.AddCommand(loginCmd)
@@ -45,6 +88,12 @@ func init() {
 	rootCmd.AddCommand(setupCmd)
 	rootCmd.AddCommand(mcpCmd)
 	rootCmd.AddCommand(requestCmd)
+	rootCmd.AddCommand(graphqlCmd)
+	rootCmd.AddCommand(doctorCmd)
+	rootCmd.AddCommand(certsCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(secretsCmd)
 }
 
 func debugLog(format string, args ...interface{}) {
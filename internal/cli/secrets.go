@@ -0,0 +1,236 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/jaknapp/applink/internal/config"
+	"github.com/jaknapp/applink/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "List and clean up what applink stores in the keychain",
+	Long: `The system keychain can't be listed, so applink keeps an index of the
+entries it stores in ~/.applink/index.json (names only, never secrets).`,
+}
+
+var secretsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List stored tokens, credentials and internal secrets",
+	Long: `List every keychain entry applink knows about, and its state:
+
+  ok       stored, and belongs to a known service
+  orphan   belongs to a service applink no longer knows (see 'secrets prune')
+  missing  in the index, but no longer in the keychain`,
+	Example: `  applink secrets list
+  applink secrets list --output json`,
+	Args:        cobra.NoArgs,
+	RunE:        runSecretsList,
+	Annotations: outputCommand,
+}
+
+var secretsPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete orphaned entries and forget missing ones",
+	Long: `Delete keychain entries for services applink no longer knows, and
+remove index entries whose keychain item is gone.`,
+	Example: `  applink secrets prune --dry-run
+  applink secrets prune --yes`,
+	Args: cobra.NoArgs,
+	RunE: runSecretsPrune,
+}
+
+var pruneDryRun bool
+
+func init() {
+	secretsPruneCmd.Flags().BoolVar(&pruneDryRun, "dry-run", false, "Show what would be removed without removing it")
+
+	secretsCmd.AddCommand(secretsListCmd)
+	secretsCmd.AddCommand(secretsPruneCmd)
+}
+
+// States of a stored entry
+const (
+	entryOK      = "ok"
+	entryOrphan  = "orphan"
+	entryMissing = "missing"
+)
+
+// storedEntry is a keychain entry and its state
+type storedEntry struct {
+	storage.IndexEntry
+	state string
+}
+
+// secretsListOutput is the schema of `applink secrets list --output json|yaml`
+type secretsListOutput struct {
+	Entries []secretsListEntry `json:"entries"`
+}
+
+type secretsListEntry struct {
+	Kind      string `json:"kind"` // token, credentials or secret
+	Name      string `json:"name"`
+	State     string `json:"state"` // ok, orphan or missing
+	UpdatedAt string `json:"updated_at"`
+}
+
+// storedEntries returns the indexed entries with their state. Tokens and
+// credentials of known services that predate the index are added to it.
+func storedEntries() ([]storedEntry, error) {
+	if err := storage.CheckKeychain(); err != nil {
+		return nil, err
+	}
+
+	indexed, err := storage.IndexedEntries()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(indexed))
+	for _, e := range indexed {
+		seen[e.Kind+"/"+e.Name] = true
+	}
+
+	var backfill []storage.IndexEntry
+	for _, service := range config.AllServices() {
+		for _, kind := range []string{storage.EntryToken, storage.EntryCredentials} {
+			if seen[kind+"/"+service.ID] {
+				continue
+			}
+			exists, err := storage.EntryExists(kind, service.ID)
+			if err != nil {
+				return nil, err
+			}
+			if exists {
+				backfill = append(backfill, storage.IndexEntry{Kind: kind, Name: service.ID})
+			}
+		}
+	}
+	if len(backfill) > 0 {
+		if err := storage.IndexEntries(backfill); err != nil {
+			return nil, err
+		}
+		if indexed, err = storage.IndexedEntries(); err != nil {
+			return nil, err
+		}
+	}
+
+	entries := make([]storedEntry, 0, len(indexed))
+	for _, e := range indexed {
+		exists, err := storage.EntryExists(e.Kind, e.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		state := entryOK
+		switch {
+		case !exists:
+			state = entryMissing
+		case e.Kind != storage.EntrySecret:
+			if _, err := config.GetService(e.Name); err != nil {
+				state = entryOrphan
+			}
+		}
+		entries = append(entries, storedEntry{IndexEntry: e, state: state})
+	}
+	return entries, nil
+}
+
+func runSecretsList(cmd *cobra.Command, args []string) error {
+	format, err := structuredFormat()
+	if err != nil {
+		return err
+	}
+	cmd.SilenceUsage = true
+
+	entries, err := storedEntries()
+	if err != nil {
+		return err
+	}
+
+	if format != "" {
+		out := &secretsListOutput{Entries: make([]secretsListEntry, 0, len(entries))}
+		for _, e := range entries {
+			out.Entries = append(out.Entries, secretsListEntry{
+				Kind:      e.Kind,
+				Name:      e.Name,
+				State:     e.state,
+				UpdatedAt: timestamp(e.UpdatedAt),
+			})
+		}
+		return writeStructured(out, format)
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("Nothing stored.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tNAME\tSTATE\tUPDATED")
+	for _, e := range entries {
+		updated := "-"
+		if !e.UpdatedAt.IsZero() {
+			updated = e.UpdatedAt.Local().Format("2006-01-02")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.Kind, e.Name, e.state, updated)
+	}
+	return w.Flush()
+}
+
+func runSecretsPrune(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	entries, err := storedEntries()
+	if err != nil {
+		return err
+	}
+
+	var orphans, missing []storedEntry
+	for _, e := range entries {
+		switch e.state {
+		case entryOrphan:
+			orphans = append(orphans, e)
+		case entryMissing:
+			missing = append(missing, e)
+		}
+	}
+	if len(orphans) == 0 && len(missing) == 0 {
+		fmt.Println("Nothing to prune.")
+		return nil
+	}
+
+	for _, e := range orphans {
+		fmt.Printf("orphan   %s %s\n", e.Kind, e.Name)
+	}
+	for _, e := range missing {
+		fmt.Printf("missing  %s %s\n", e.Kind, e.Name)
+	}
+	if pruneDryRun {
+		return nil
+	}
+
+	if len(orphans) > 0 {
+		ok, err := confirmNo(fmt.Sprintf("Delete the orphaned keychain entries (%d)?", len(orphans)), "Pass --yes to delete them.")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			orphans = nil
+		}
+	}
+
+	for _, e := range orphans {
+		if err := storage.DeleteEntry(e.Kind, e.Name); err != nil {
+			return fmt.Errorf("failed to delete %s %s: %w", e.Kind, e.Name, err)
+		}
+	}
+	for _, e := range missing {
+		storage.ForgetEntry(e.Kind, e.Name)
+	}
+
+	fmt.Printf("✓ Pruned %d orphaned and %d missing entries\n", len(orphans), len(missing))
+	return nil
+}
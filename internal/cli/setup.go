@@ -1,18 +1,16 @@
 package cli
 
 import (
-	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"runtime"
 	"strings"
-	"syscall"
 
 	"github.com/jaknapp/applink/internal/config"
 	"github.com/jaknapp/applink/internal/storage"
 	"github.com/spf13/cobra"
-	"golang.org/x/term"
 )
 
 var setupCmd = &cobra.Command{
@@ -21,54 +19,91 @@ var setupCmd = &cobra.Command{
 	Long: `Set up OAuth client credentials for a service.
 
 This will guide you through creating an OAuth application and
-store the credentials securely in your system keychain.`,
+store the credentials securely in your system keychain.
+
+With --output json or yaml, the instructions and prompts go to stderr and
+only the result is printed to stdout.
+
+For scripts, pass the client ID with --client-id and pipe the secret to
+--client-secret-stdin; no prompts are shown.`,
 	Example: `  applink setup slack
-  applink setup notion`,
-	Args: cobra.ExactArgs(1),
-	RunE: runSetup,
+  applink setup notion
+  printf %s "$SECRET" | applink setup linear --client-id abc123 --client-secret-stdin`,
+	Args:        cobra.ExactArgs(1),
+	RunE:        runSetup,
+	Annotations: outputCommand,
 }
 
-var openBrowserFlag bool
+var (
+	openBrowserFlag  bool
+	setupClientID    string
+	setupSecretStdin bool
+)
 
 func init() {
 	setupCmd.Flags().BoolVarP(&openBrowserFlag, "open", "o", false, "Open the setup URL in your browser")
+	setupCmd.Flags().StringVar(&setupClientID, "client-id", "", "OAuth client ID (skips the prompt)")
+	setupCmd.Flags().BoolVar(&setupSecretStdin, "client-secret-stdin", false, "Read the OAuth client secret from stdin (skips the prompt)")
 }
 
 func runSetup(cmd *cobra.Command, args []string) error {
 	serviceName := args[0]
 
+	format, err := structuredFormat()
+	if err != nil {
+		return err
+	}
+
 	service, err := config.GetService(serviceName)
 	if err != nil {
 		return err
 	}
 
-	// Check if using environment variables
+	// Without prompts, both values must come from flags
 	envPrefix := fmt.Sprintf("APPLINK_%s_", strings.ToUpper(serviceName))
-	if os.Getenv(envPrefix+"CLIENT_ID") != "" {
-		fmt.Printf("Note: Environment variables are set for %s.\n", service.Name)
-		fmt.Printf("Keychain credentials will be used as fallback when env vars are not set.\n\n")
+	if nonInteractive() && (setupClientID == "" || !setupSecretStdin) {
+		cmd.SilenceUsage = true
+		return inputRequired("setup "+serviceName, fmt.Sprintf(
+			"Pass --client-id and pipe the secret to --client-secret-stdin, or set %sCLIENT_ID and %sCLIENT_SECRET instead.",
+			envPrefix, envPrefix))
 	}
 
-	fmt.Printf("Setting up %s OAuth credentials\n", service.Name)
-	fmt.Println(strings.Repeat("─", 50))
-	fmt.Println()
-
-	if service.SetupURL != "" {
-		fmt.Printf("Create an OAuth app at: %s\n\n", service.SetupURL)
-		if openBrowserFlag {
-			openSetupURL(service.SetupURL)
-		}
+	// Keep stdout for the machine-readable result
+	out := io.Writer(os.Stdout)
+	if format != "" {
+		out = os.Stderr
 	}
 
-	if service.SetupInstructions != "" {
-		fmt.Println(service.SetupInstructions)
-		fmt.Println()
+	// Check if using environment variables
+	envOverride := os.Getenv(envPrefix+"CLIENT_ID") != ""
+	if envOverride {
+		fmt.Fprintf(out, "Note: Environment variables are set for %s.\n", service.Name)
+		fmt.Fprintf(out, "Keychain credentials will be used as fallback when env vars are not set.\n\n")
 	}
 
-	fmt.Println(strings.Repeat("─", 50))
+	// The instructions are only useful to someone about to be prompted
+	if setupClientID == "" || !setupSecretStdin {
+		fmt.Fprintf(out, "Setting up %s OAuth credentials\n", service.Name)
+		fmt.Fprintln(out, strings.Repeat("─", 50))
+		fmt.Fprintln(out)
+
+		if service.SetupURL != "" {
+			fmt.Fprintf(out, "Create an OAuth app at: %s\n\n", service.SetupURL)
+			if openBrowserFlag {
+				openSetupURL(service.SetupURL)
+			}
+		}
+
+		if service.SetupInstructions != "" {
+			fmt.Fprintln(out, service.SetupInstructions)
+			fmt.Fprintln(out)
+		}
+
+		fmt.Fprintln(out, strings.Repeat("─", 50))
+	}
 
 	// Prompt for credentials
-	creds, err := promptCredentials(service)
+	creds, err := promptCredentials(out, service)
 	if err != nil {
 		return err
 	}
@@ -81,43 +116,54 @@ func runSetup(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to store credentials: %w", err)
 	}
 
+	if format != "" {
+		return writeStructured(&setupOutput{
+			Service:     serviceName,
+			ClientID:    creds.ClientID,
+			Storage:     "keychain",
+			EnvOverride: envOverride,
+		}, format)
+	}
+
 	fmt.Printf("\n✓ Credentials saved to keychain for %s\n", service.Name)
 	fmt.Printf("  Run 'applink login %s' to authenticate.\n", serviceName)
 	return nil
 }
 
-func promptCredentials(service *config.Service) (*storage.Credentials, error) {
-	reader := bufio.NewReader(os.Stdin)
+// setupOutput is the schema of `applink setup --output json|yaml`
+type setupOutput struct {
+	Service     string `json:"service"`
+	ClientID    string `json:"client_id"`
+	Storage     string `json:"storage"`      // Where the credentials were saved
+	EnvOverride bool   `json:"env_override"` // APPLINK_<SERVICE>_CLIENT_ID takes precedence
+}
 
+// promptCredentials reads the client ID and secret, from --client-id and
+// --client-secret-stdin if given and from prompts otherwise
+func promptCredentials(out io.Writer, service *config.Service) (*storage.Credentials, error) {
 	// Client ID (visible input)
-	fmt.Print("Client ID: ")
-	clientID, err := reader.ReadString('\n')
-	if err != nil {
-		return nil, fmt.Errorf("failed to read client ID: %w", err)
+	clientID := setupClientID
+	if clientID == "" {
+		var err error
+		if clientID, err = promptLine(out, "Client ID: "); err != nil {
+			return nil, fmt.Errorf("failed to read client ID: %w", err)
+		}
 	}
-	clientID = strings.TrimSpace(clientID)
 	if clientID == "" {
 		return nil, fmt.Errorf("client ID cannot be empty")
 	}
 
 	// Client Secret (hidden input)
-	fmt.Print("Client Secret: ")
 	var clientSecret string
-	if term.IsTerminal(int(syscall.Stdin)) {
-		secretBytes, err := term.ReadPassword(int(syscall.Stdin))
-		if err != nil {
-			return nil, fmt.Errorf("failed to read client secret: %w", err)
-		}
-		clientSecret = string(secretBytes)
-		fmt.Println() // New line after hidden input
+	var err error
+	if setupSecretStdin {
+		clientSecret, err = readSecretStdin()
 	} else {
-		secret, err := reader.ReadString('\n')
-		if err != nil {
-			return nil, fmt.Errorf("failed to read client secret: %w", err)
-		}
-		clientSecret = strings.TrimSpace(secret)
+		clientSecret, err = promptSecret(out, "Client Secret: ")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client secret: %w", err)
 	}
-
 	if clientSecret == "" {
 		return nil, fmt.Errorf("client secret cannot be empty")
 	}
@@ -3,6 +3,7 @@ package cli
 import (
 	"fmt"
 	"os"
+	"strings"
 	"text/tabwriter"
 	"time"
 
@@ -14,40 +15,259 @@ import (
 var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Show authentication status for all services",
-	Long:  `Display the authentication status for all supported services.`,
-	RunE:  runStatus,
+	Long: `Display the authentication status for all supported services.
+
+By default only the stored tokens are inspected. With --verify, each token
+is also checked against the service's API, which catches tokens that were
+revoked or lack scopes applink requests. The command then exits non-zero if
+any token is revoked or has insufficient scopes.`,
+	Example: `  applink status
+  applink status --verify --timeout 5s`,
+	RunE:        runStatus,
+	Annotations: outputCommand,
+}
+
+var (
+	statusVerify  bool
+	statusTimeout time.Duration
+)
+
+func init() {
+	statusCmd.Flags().BoolVar(&statusVerify, "verify", false, "Check each token against the service's API")
+	statusCmd.Flags().DurationVar(&statusTimeout, "timeout", 10*time.Second, "Timeout for each check with --verify")
 }
 
 func runStatus(cmd *cobra.Command, args []string) error {
+	format, err := structuredFormat()
+	if err != nil {
+		return err
+	}
+
 	services := config.AllServices()
 
+	tokens := make([]*storage.Token, len(services))
+	sources := make([]string, len(services))
+	lookupErrs := make([]error, len(services))
+	for i, service := range services {
+		tokens[i], sources[i], lookupErrs[i] = storage.LookupToken(service.ID)
+	}
+
+	var checks []tokenCheck
+	if statusVerify {
+		checks = verifyTokens(cmd.Context(), services, tokens, statusTimeout)
+	}
+
+	if format != "" {
+		if err := writeStructured(statusReport(services, tokens, sources, lookupErrs, checks), format); err != nil {
+			return err
+		}
+	} else if err := printStatusTable(services, tokens, sources, lookupErrs, checks); err != nil {
+		return err
+	}
+
+	if !statusVerify {
+		return nil
+	}
+
+	// A failed check is a result, not a usage mistake
+	cmd.SilenceUsage = true
+	if format != "" {
+		return checkFailures(checks)
+	}
+	return reportChecks(services, checks)
+}
+
+func printStatusTable(services []*config.Service, tokens []*storage.Token, sources []string, lookupErrs []error, checks []tokenCheck) error {
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "SERVICE\tSTATUS\tUSER\tEXPIRES")
+	header := "SERVICE\tSTATUS\tSOURCE\tUSER\tWORKSPACE\tEXPIRES"
+	if checks != nil {
+		header += "\tVERIFIED"
+	}
+	fmt.Fprintln(w, header)
 
-	for _, service := range services {
-		token, err := storage.GetToken(service.ID)
-		if err != nil || token == nil {
-			fmt.Fprintf(w, "%s\t✗ not configured\t\t\n", service.ID)
+	for i, service := range services {
+		token := tokens[i]
+		if lookupErrs[i] != nil {
+			fmt.Fprintf(w, "%s\t✗ error: %v\t\t\t\t\n", service.ID, lookupErrs[i])
+			continue
+		}
+		if token == nil {
+			fmt.Fprintf(w, "%s\t✗ not configured\t\t\t\t\n", service.ID)
 			continue
 		}
 
-		// Check if token is expired
 		status := "✓ active"
+		if tokenState(token) == "expired" {
+			status = "✗ expired"
+		}
 		expires := "never"
 		if !token.ExpiresAt.IsZero() {
-			if token.ExpiresAt.Before(time.Now()) {
-				status = "✗ expired"
-			}
 			expires = token.ExpiresAt.Format("2006-01-02")
 		}
 
-		user := token.User
-		if user == "" {
-			user = "-"
+		row := fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s", service.ID, status, sources[i], orDash(token.User), orDash(token.Workspace), expires)
+		if checks != nil {
+			row += "\t" + verdictLabel(checks[i].verdict)
 		}
-
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", service.ID, status, user, expires)
+		fmt.Fprintln(w, row)
 	}
 
 	return w.Flush()
 }
+
+// tokenState is "active", "expired" or, for a nil token, "not_configured"
+func tokenState(token *storage.Token) string {
+	switch {
+	case token == nil:
+		return "not_configured"
+	case !token.ExpiresAt.IsZero() && token.ExpiresAt.Before(time.Now()):
+		return "expired"
+	default:
+		return "active"
+	}
+}
+
+// statusOutput is the schema of `applink status --output json|yaml`
+type statusOutput struct {
+	Services []statusEntry `json:"services"`
+}
+
+type statusEntry struct {
+	Service         string             `json:"service"`
+	Name            string             `json:"name"`
+	AuthType        string             `json:"auth_type"`
+	Status          string             `json:"status"`
+	Source          string             `json:"source"` // env, keychain, or "" if not configured
+	Error           string             `json:"error"`  // Why the token couldn't be read
+	User            string             `json:"user"`
+	UserID          string             `json:"user_id"`
+	Workspace       string             `json:"workspace"`
+	WorkspaceID     string             `json:"workspace_id"`
+	ExpiresAt       string             `json:"expires_at"`
+	Scopes          []string           `json:"scopes"`
+	RequestedScopes []string           `json:"requested_scopes"`
+	Verification    *verificationEntry `json:"verification,omitempty"`
+}
+
+type verificationEntry struct {
+	Result        string   `json:"result"`
+	Error         string   `json:"error"`
+	MissingScopes []string `json:"missing_scopes"`
+}
+
+func statusReport(services []*config.Service, tokens []*storage.Token, sources []string, lookupErrs []error, checks []tokenCheck) *statusOutput {
+	report := &statusOutput{Services: make([]statusEntry, 0, len(services))}
+
+	for i, service := range services {
+		entry := statusEntry{
+			Service:         service.ID,
+			Name:            service.Name,
+			AuthType:        string(service.AuthType),
+			Status:          tokenState(tokens[i]),
+			Source:          sources[i],
+			Scopes:          []string{},
+			RequestedScopes: []string{},
+		}
+		if err := lookupErrs[i]; err != nil {
+			entry.Status = "error"
+			entry.Error = err.Error()
+		}
+
+		if token := tokens[i]; token != nil {
+			entry.User = token.User
+			entry.UserID = token.UserID
+			entry.Workspace = token.Workspace
+			entry.WorkspaceID = token.WorkspaceID
+			entry.ExpiresAt = timestamp(token.ExpiresAt)
+			entry.Scopes = append(entry.Scopes, splitScopes(token.Scope)...)
+			entry.RequestedScopes = append(entry.RequestedScopes, requestedScopesOf(service, token)...)
+
+			if checks != nil {
+				check := checks[i]
+				entry.Verification = &verificationEntry{
+					Result:        check.verdict,
+					MissingScopes: append([]string{}, check.missing...),
+				}
+				if check.err != nil {
+					entry.Verification.Error = check.err.Error()
+				}
+			}
+		}
+
+		report.Services = append(report.Services, entry)
+	}
+
+	return report
+}
+
+// reportChecks prints details for tokens that failed verification and
+// returns an error if any token is unusable
+func reportChecks(services []*config.Service, checks []tokenCheck) error {
+	printed := false
+	for i, check := range checks {
+		if check.verdict == "" || check.verdict == verdictValid {
+			continue
+		}
+		if !printed {
+			fmt.Println()
+			printed = true
+		}
+
+		id := services[i].ID
+		switch check.verdict {
+		case verdictRevoked:
+			fmt.Printf("%s: token was rejected: %v\n", id, check.err)
+			fmt.Printf("  Run: applink login %s\n", id)
+		case verdictInsufficientScope:
+			if check.err != nil {
+				fmt.Printf("%s: %v\n", id, check.err)
+			}
+			if len(check.missing) > 0 {
+				fmt.Printf("%s: missing scopes: %s\n", id, strings.Join(check.missing, ", "))
+				fmt.Printf("  Granted:   %s\n", strings.Join(check.granted, ", "))
+				fmt.Printf("  Requested: %s\n", strings.Join(check.requested, ", "))
+			}
+			fmt.Printf("  Run: applink login %s\n", id)
+		default:
+			fmt.Printf("%s: could not verify: %v\n", id, check.err)
+		}
+	}
+
+	return checkFailures(checks)
+}
+
+// checkFailures returns an error if any token was revoked or lacks scopes
+func checkFailures(checks []tokenCheck) error {
+	failed := 0
+	for _, check := range checks {
+		if check.verdict == verdictRevoked || check.verdict == verdictInsufficientScope {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return &exitError{code: 1, err: fmt.Errorf("%d token(s) failed verification", failed)}
+	}
+	return nil
+}
+
+func verdictLabel(verdict string) string {
+	switch verdict {
+	case verdictValid:
+		return "✓ valid"
+	case verdictRevoked:
+		return "✗ revoked"
+	case verdictInsufficientScope:
+		return "⚠ insufficient scope"
+	default:
+		return "? unknown"
+	}
+}
+
+// orDash returns s, or "-" if it is empty
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
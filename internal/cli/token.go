@@ -12,18 +12,38 @@ var tokenCmd = &cobra.Command{
 	Use:   "token <service>",
 	Short: "Print the access token for a service",
 	Long: `Print the access token for a service to stdout.
-Useful for scripts or debugging.`,
+Useful for scripts or debugging.
+
+With --output json or yaml, the token is printed along with its metadata
+(expiry, scopes and the connected account).
+
+Slack logins can store both a user token and a bot token; choose one with
+--token-kind user|bot. By default the user token is used.`,
 	Example: `  applink token slack
-  applink token notion | pbcopy`,
-	Args: cobra.ExactArgs(1),
-	RunE: runToken,
+  applink token notion | pbcopy
+  applink token linear --output json
+  applink token slack --token-kind bot`,
+	Args:        cobra.ExactArgs(1),
+	RunE:        runToken,
+	Annotations: outputCommand,
+}
+
+var tokenKind string
+
+func init() {
+	tokenCmd.Flags().StringVar(&tokenKind, "token-kind", "", "Token to print for services with several: user or bot")
 }
 
 func runToken(cmd *cobra.Command, args []string) error {
 	serviceName := args[0]
 
+	format, err := structuredFormat()
+	if err != nil {
+		return err
+	}
+
 	// Verify service exists
-	if _, err := config.GetService(serviceName); err != nil {
+	if _, err = config.GetService(serviceName); err != nil {
 		return err
 	}
 
@@ -34,7 +54,54 @@ func runToken(cmd *cobra.Command, args []string) error {
 	if token == nil {
 		return fmt.Errorf("not authenticated with %s. Run: applink login %s", serviceName, serviceName)
 	}
+	if token, err = selectToken(serviceName, token, tokenKind); err != nil {
+		return err
+	}
+
+	if format != "" {
+		return writeStructured(tokenReport(serviceName, token), format)
+	}
 
 	fmt.Print(token.AccessToken)
 	return nil
 }
+
+// selectToken picks the user or bot token for --token-kind
+func selectToken(serviceName string, token *storage.Token, kind string) (*storage.Token, error) {
+	selected, err := token.ForKind(kind)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", serviceName, err)
+	}
+	return selected, nil
+}
+
+// tokenOutput is the schema of `applink token --output json|yaml`
+type tokenOutput struct {
+	Service     string   `json:"service"`
+	AccessToken string   `json:"access_token"`
+	TokenType   string   `json:"token_type"`
+	ExpiresAt   string   `json:"expires_at"`
+	Scopes      []string `json:"scopes"`
+	Requested   []string `json:"requested_scopes"`
+	User        string   `json:"user"`
+	UserID      string   `json:"user_id"`
+	Workspace   string   `json:"workspace"`
+	WorkspaceID string   `json:"workspace_id"`
+	TeamID      string   `json:"team_id"`
+}
+
+func tokenReport(serviceName string, token *storage.Token) *tokenOutput {
+	return &tokenOutput{
+		Service:     serviceName,
+		AccessToken: token.AccessToken,
+		TokenType:   token.TokenType,
+		ExpiresAt:   timestamp(token.ExpiresAt),
+		Scopes:      append([]string{}, splitScopes(token.Scope)...),
+		Requested:   append([]string{}, token.RequestedScopes...),
+		User:        token.User,
+		UserID:      token.UserID,
+		Workspace:   token.Workspace,
+		WorkspaceID: token.WorkspaceID,
+		TeamID:      token.TeamID,
+	}
+}
@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/jaknapp/applink/internal/auth"
+	"github.com/jaknapp/applink/internal/har"
+)
+
+// harRecorder captures API traffic when --debug or --har is set
+var harRecorder *har.Recorder
+
+// setupHTTPLogging starts recording API traffic if requested. It runs
+// before every command.
+func setupHTTPLogging() {
+	if !debug && harFile == "" {
+		return
+	}
+
+	harRecorder = har.NewRecorder(version)
+	auth.SetTransport(harRecorder.Wrap(http.DefaultTransport))
+}
+
+// httpTransport wraps a transport so its traffic is recorded when HTTP
+// logging is enabled
+func httpTransport(base http.RoundTripper) http.RoundTripper {
+	if harRecorder == nil {
+		return base
+	}
+	return harRecorder.Wrap(base)
+}
+
+// newHTTPClient returns a client for API requests
+func newHTTPClient() *http.Client {
+	return &http.Client{Transport: httpTransport(http.DefaultTransport)}
+}
+
+// flushHTTPLog writes recorded traffic to the --har file, or to stderr
+// under --debug
+func flushHTTPLog() {
+	if harRecorder == nil {
+		return
+	}
+
+	if harFile != "" {
+		if err := harRecorder.WriteFile(harFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write HAR file: %v\n", err)
+			return
+		}
+		debugLog("Wrote %d HTTP exchange(s) to %s", harRecorder.Len(), harFile)
+		return
+	}
+
+	if harRecorder.Len() > 0 {
+		fmt.Fprintln(os.Stderr, "[DEBUG] HTTP traffic (HAR 1.2):")
+		harRecorder.Write(os.Stderr)
+	}
+}
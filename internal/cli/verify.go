@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jaknapp/applink/internal/api"
+	"github.com/jaknapp/applink/internal/config"
+	"github.com/jaknapp/applink/internal/storage"
+)
+
+// Token verification results
+const (
+	verdictValid             = "valid"
+	verdictRevoked           = "revoked"
+	verdictInsufficientScope = "insufficient_scope"
+	verdictUnknown           = "unknown" // The check itself failed (network error, timeout)
+)
+
+// tokenCheck is the result of checking a stored token against the live API
+type tokenCheck struct {
+	verdict   string
+	err       error    // Why the token is not valid, if known
+	granted   []string // Scopes the token was granted
+	requested []string // Scopes requested at login
+	missing   []string // Requested scopes that were not granted
+}
+
+// verifyTokens checks tokens concurrently. Each check is bounded by
+// timeout. Results are in the same order as services.
+func verifyTokens(ctx context.Context, services []*config.Service, tokens []*storage.Token, timeout time.Duration) []tokenCheck {
+	checks := make([]tokenCheck, len(services))
+	client := newHTTPClient()
+
+	var wg sync.WaitGroup
+	for i := range services {
+		if tokens[i] == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			checks[i] = verifyToken(ctx, client, services[i], tokens[i])
+		}(i)
+	}
+	wg.Wait()
+
+	return checks
+}
+
+// verifyToken calls the service's identity endpoint with the token and
+// compares the granted scopes with the ones applink requests
+func verifyToken(ctx context.Context, client *http.Client, service *config.Service, token *storage.Token) tokenCheck {
+	// A Slack install may only have granted a bot token; check whichever
+	// token is used by default, against the scopes requested for it
+	used, err := token.ForKind("")
+	if err != nil {
+		return tokenCheck{verdict: verdictUnknown, err: err}
+	}
+	requested := requestedScopesOf(service, used)
+	if token.AccessToken == "" {
+		// Bot scopes have no service defaults to fall back to
+		requested = used.RequestedScopes
+	}
+
+	check := tokenCheck{
+		granted:   splitScopes(used.Scope),
+		requested: requested,
+	}
+	check.missing = missingScopes(check.requested, check.granted)
+
+	_, err = api.LookupIdentity(ctx, client, service, used)
+	var apiErr *api.Error
+	switch {
+	case err == nil:
+		check.verdict = verdictValid
+		if len(check.missing) > 0 {
+			check.verdict = verdictInsufficientScope
+		}
+	case errors.As(err, &apiErr) && apiErr.IsAuthError():
+		check.verdict = verdictRevoked
+		check.err = err
+	case errors.As(err, &apiErr) && apiErr.IsScopeError():
+		check.verdict = verdictInsufficientScope
+		check.err = err
+	default:
+		check.verdict = verdictUnknown
+		check.err = err
+	}
+
+	return check
+}
+
+// requestedScopesOf returns the scopes requested for a token. Tokens from
+// before scopes were recorded requested the service's defaults.
+func requestedScopesOf(service *config.Service, token *storage.Token) []string {
+	if token.RequestedScopes != nil {
+		return token.RequestedScopes
+	}
+	return service.Scopes
+}
+
+// splitScopes splits a granted scope string. Slack separates scopes with
+// commas, OAuth 2.0 with spaces.
+func splitScopes(scope string) []string {
+	return strings.FieldsFunc(scope, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
+
+// missingScopes returns the requested scopes that are not in granted.
+// Nothing is missing if the service didn't report granted scopes.
+func missingScopes(requested, granted []string) []string {
+	if len(granted) == 0 {
+		return nil
+	}
+
+	have := make(map[string]bool, len(granted))
+	for _, s := range granted {
+		have[s] = true
+	}
+
+	var missing []string
+	for _, s := range requested {
+		if !have[s] {
+			missing = append(missing, s)
+		}
+	}
+	return missing
+}
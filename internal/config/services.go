@@ -1,6 +1,9 @@
 package config
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+)
 
 // AuthType represents the authentication method for a service
 type AuthType string
@@ -17,16 +20,30 @@ type Service struct {
 	AuthType AuthType // oauth or apikey
 
 	// OAuth configuration
-	AuthURL  string   // OAuth authorization URL
-	TokenURL string   // OAuth token exchange URL
-	Scopes   []string // OAuth scopes to request
+	AuthURL   string   // OAuth authorization URL
+	TokenURL  string   // OAuth token exchange URL
+	Scopes    []string // OAuth scopes to request
+	BotScopes []string // Bot token scopes to request (Slack)
+
+	// OAuth callback configuration
+	CallbackPorts   []int  // Redirect URI ports registered with the provider (default 8888)
+	AnyCallbackPort bool   // Provider accepts any loopback port (RFC 8252), so a free one is picked
+	RedirectHost    string // Redirect URI host: localhost (default), 127.0.0.1 or [::1]
 
 	// API configuration
-	APIURL string // Base URL for API requests
+	APIURL     string // Base URL for API requests
+	GraphQLURL string // GraphQL endpoint, if the service has one
+
+	// Identity lookup: the endpoint that reports who a token belongs to
+	IdentityPath  string // Path relative to APIURL (e.g. "/api/auth.test")
+	IdentityQuery string // GraphQL query sent to GraphQLURL instead of IdentityPath
 
 	// MCP configuration
 	MCPPackage string            // npm package name for MCP server
 	MCPEnvVars map[string]string // Environment variable mappings
+	// MCPTokenKind is the token the MCP server uses by default when one of
+	// that kind is stored (see storage.Token.ForKind)
+	MCPTokenKind string
 
 	// Setup instructions
 	SetupURL          string // URL to create OAuth app
@@ -49,13 +66,15 @@ var serviceRegistry = map[string]*Service{
 			"chat:write",
 			"users:read",
 		},
-		APIURL:     "https://slack.com",
-		MCPPackage: "@modelcontextprotocol/server-slack",
+		APIURL:       "https://slack.com",
+		IdentityPath: "/api/auth.test",
+		MCPPackage:   "@modelcontextprotocol/server-slack",
 		MCPEnvVars: map[string]string{
 			"SLACK_BOT_TOKEN": "access_token",
 			"SLACK_TEAM_ID":   "team_id",
 		},
-		SetupURL: "https://api.slack.com/apps",
+		MCPTokenKind: "bot",
+		SetupURL:     "https://api.slack.com/apps",
 		SetupInstructions: `1. Go to https://api.slack.com/apps
 2. Click "Create New App" → "From scratch"
 3. Name your app (e.g., "applink") and select your workspace
@@ -68,14 +87,15 @@ var serviceRegistry = map[string]*Service{
 7. Go to "Basic Information" to find your Client ID and Client Secret`,
 	},
 	"notion": {
-		ID:         "notion",
-		Name:       "Notion",
-		AuthType:   AuthTypeOAuth,
-		AuthURL:    "https://api.notion.com/v1/oauth/authorize",
-		TokenURL:   "https://api.notion.com/v1/oauth/token",
-		Scopes:     []string{}, // Notion doesn't use scopes in the same way
-		APIURL:     "https://api.notion.com",
-		MCPPackage: "@modelcontextprotocol/server-notion",
+		ID:           "notion",
+		Name:         "Notion",
+		AuthType:     AuthTypeOAuth,
+		AuthURL:      "https://api.notion.com/v1/oauth/authorize",
+		TokenURL:     "https://api.notion.com/v1/oauth/token",
+		Scopes:       []string{}, // Notion doesn't use scopes in the same way
+		APIURL:       "https://api.notion.com",
+		IdentityPath: "/v1/users/me",
+		MCPPackage:   "@modelcontextprotocol/server-notion",
 		MCPEnvVars: map[string]string{
 			"NOTION_API_TOKEN": "access_token",
 		},
@@ -103,6 +123,11 @@ Note: After authenticating, you must share specific pages with the integration.`
 			"comments:create",
 		},
 		APIURL:     "https://api.linear.app",
+		GraphQLURL: "https://api.linear.app/graphql",
+		IdentityQuery: `{
+  viewer { id name email }
+  organization { id name }
+}`,
 		MCPPackage: "@linear/mcp-server",
 		MCPEnvVars: map[string]string{
 			"LINEAR_API_KEY": "access_token",
@@ -116,13 +141,14 @@ Note: After authenticating, you must share specific pages with the integration.`
 6. Copy the "Client ID" and "Client Secret"`,
 	},
 	"honeycomb": {
-		ID:         "honeycomb",
-		Name:       "Honeycomb",
-		AuthType:   AuthTypeAPIKey,
-		APIURL:     "https://api.honeycomb.io",
-		MCPPackage: "", // No MCP server yet
-		MCPEnvVars: nil,
-		SetupURL:   "https://ui.honeycomb.io/account",
+		ID:           "honeycomb",
+		Name:         "Honeycomb",
+		AuthType:     AuthTypeAPIKey,
+		APIURL:       "https://api.honeycomb.io",
+		IdentityPath: "/1/auth",
+		MCPPackage:   "", // No MCP server yet
+		MCPEnvVars:   nil,
+		SetupURL:     "https://ui.honeycomb.io/account",
 		SetupInstructions: `1. Go to https://ui.honeycomb.io/account
 2. Navigate to "Team settings" → "API Keys"
 3. Create a new API key with the permissions you need
@@ -139,20 +165,24 @@ func GetService(name string) (*Service, error) {
 	return service, nil
 }
 
-// AllServices returns all registered services
+// AllServices returns all registered services, sorted by ID
 func AllServices() []*Service {
 	services := make([]*Service, 0, len(serviceRegistry))
 	for _, s := range serviceRegistry {
 		services = append(services, s)
 	}
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].ID < services[j].ID
+	})
 	return services
 }
 
-// ServiceNames returns the names of all registered services
+// ServiceNames returns the names of all registered services, sorted
 func ServiceNames() []string {
 	names := make([]string, 0, len(serviceRegistry))
 	for name := range serviceRegistry {
 		names = append(names, name)
 	}
+	sort.Strings(names)
 	return names
 }
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const settingsFile = "config.json"
+
+// Settings holds user preferences stored in ~/.applink/config.json
+type Settings struct {
+	// CallbackPort is the port for OAuth callbacks (default 8888)
+	CallbackPort int `json:"callback_port,omitempty"`
+
+	// CallbackTimeout is how long to wait for the browser to complete an
+	// OAuth login, as a duration like "10m" (default 5m)
+	CallbackTimeout string `json:"callback_timeout,omitempty"`
+
+	// SuccessRedirectURL, if set, is where the browser is sent after a
+	// successful login instead of the built-in success page
+	SuccessRedirectURL string `json:"success_redirect_url,omitempty"`
+
+	// CAKeyStorage is where a new CA private key is kept: "encrypted"
+	// (file encrypted with a key in the keychain), "keyring", "file", or
+	// "auto" (encrypted if the keychain is available; the default)
+	CAKeyStorage string `json:"ca_key_storage,omitempty"`
+
+	// TrustStore is where the CA is installed on Linux: "system", "user"
+	// (Firefox and Chromium NSS databases only) or "all" (the default)
+	TrustStore string `json:"trust_store,omitempty"`
+
+	// Services holds per-service overrides, keyed by service ID
+	Services map[string]*ServiceSettings `json:"services,omitempty"`
+}
+
+// ServiceSettings overrides a service's defaults
+type ServiceSettings struct {
+	// CallbackPorts are the redirect URI ports registered with the
+	// provider, tried in order until one is free
+	CallbackPorts []int `json:"callback_ports,omitempty"`
+
+	// AnyCallbackPort marks providers that accept any loopback port
+	// (RFC 8252), so a free port is picked automatically
+	AnyCallbackPort bool `json:"any_callback_port,omitempty"`
+
+	// RedirectHost is the redirect URI host: localhost, 127.0.0.1 or
+	// [::1]. Some providers reject localhost.
+	RedirectHost string `json:"redirect_host,omitempty"`
+
+	// Scopes replaces the OAuth scopes applink requests by default
+	Scopes []string `json:"scopes,omitempty"`
+
+	// BotScopes are bot token scopes to request (Slack), in addition to
+	// the user scopes
+	BotScopes []string `json:"bot_scopes,omitempty"`
+}
+
+// GetConfigDir returns the applink configuration directory (~/.applink)
+func GetConfigDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".applink"), nil
+}
+
+// GetSettingsPath returns the path to the settings file
+func GetSettingsPath() (string, error) {
+	dir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, settingsFile), nil
+}
+
+// LoadSettings reads the settings file. A missing file yields empty settings.
+func LoadSettings() (*Settings, error) {
+	path, err := GetSettingsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Settings{}, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var settings Settings
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return &settings, nil
+}
+
+// SaveSettings writes the settings file
+func SaveSettings(settings *Settings) error {
+	path, err := GetSettingsPath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0600)
+}
+
+// ServiceSettings returns the overrides for a service, or empty settings
+// if there are none
+func (s *Settings) ServiceSettings(id string) *ServiceSettings {
+	if ss, ok := s.Services[id]; ok && ss != nil {
+		return ss
+	}
+	return &ServiceSettings{}
+}
@@ -0,0 +1,138 @@
+package har
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/url"
+	"strings"
+
+	"github.com/jaknapp/applink/internal/api"
+)
+
+const redacted = "[REDACTED]"
+
+// isSecretKey reports whether a form field, query parameter or JSON key
+// holds a token or client secret
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	switch key {
+	case "token", "client_secret", "password", "secret":
+		return true
+	}
+	return strings.HasSuffix(key, "_token") || strings.HasSuffix(key, "_secret")
+}
+
+// isSecretParam is isSecretKey for form fields and query parameters, where
+// "code" is the OAuth authorization code. In JSON bodies "code" is usually
+// an error code, which is worth keeping.
+func isSecretParam(key string) bool {
+	return strings.EqualFold(key, "code") || isSecretKey(key)
+}
+
+// secretNestedKeys are JSON keys that are only secret inside a particular
+// object, e.g. Slack's incoming_webhook.url, which anyone can post to
+var secretNestedKeys = map[string]string{
+	"incoming_webhook": "url",
+}
+
+func redactHeader(name, value string) string {
+	if api.IsSensitiveHeader(name) {
+		return redacted
+	}
+	return value
+}
+
+func redactValue(name, value string) string {
+	if isSecretParam(name) {
+		return redacted
+	}
+	return value
+}
+
+func redactURL(u *url.URL) string {
+	redactedURL := *u
+	if u.User != nil {
+		redactedURL.User = url.User(redacted)
+	}
+
+	q := u.Query()
+	changed := false
+	for name, values := range q {
+		if isSecretParam(name) {
+			for i := range values {
+				values[i] = redacted
+			}
+			changed = true
+		}
+	}
+	if changed {
+		redactedURL.RawQuery = q.Encode()
+	}
+
+	return redactedURL.String()
+}
+
+// redactBody removes secrets from form-encoded and JSON bodies. Other
+// bodies are recorded as-is.
+func redactBody(contentType string, body []byte) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return string(body)
+		}
+		for name, vals := range values {
+			if isSecretParam(name) {
+				for i := range vals {
+					vals[i] = redacted
+				}
+			}
+		}
+		return values.Encode()
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") || json.Valid(body):
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return string(body)
+		}
+		out, err := json.Marshal(redactJSON(v))
+		if err != nil {
+			return string(body)
+		}
+		return string(out)
+	default:
+		return string(body)
+	}
+}
+
+func redactJSON(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			if _, isString := item.(string); isString && isSecretKey(k) {
+				val[k] = redacted
+				continue
+			}
+			if nested, ok := item.(map[string]interface{}); ok {
+				if key, ok := secretNestedKeys[strings.ToLower(k)]; ok {
+					if _, isString := nested[key].(string); isString {
+						nested[key] = redacted
+					}
+				}
+			}
+			val[k] = redactJSON(item)
+		}
+		return val
+	case []interface{}:
+		for i, item := range val {
+			val[i] = redactJSON(item)
+		}
+		return val
+	default:
+		return v
+	}
+}
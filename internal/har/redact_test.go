@@ -0,0 +1,92 @@
+package har
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRedactFormBody(t *testing.T) {
+	body := "grant_type=authorization_code&code=the-code&client_id=id&client_secret=shh&redirect_uri=http%3A%2F%2Flocalhost%3A8080%2Fcallback"
+
+	got, err := url.ParseQuery(redactBody("application/x-www-form-urlencoded", []byte(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"code", "client_secret"} {
+		if v := got.Get(name); v != redacted {
+			t.Errorf("%s = %q, want %q", name, v, redacted)
+		}
+	}
+	for name, want := range map[string]string{"grant_type": "authorization_code", "client_id": "id"} {
+		if v := got.Get(name); v != want {
+			t.Errorf("%s = %q, want %q", name, v, want)
+		}
+	}
+}
+
+func TestRedactJSONErrorBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		keep []string
+	}{
+		{
+			"Notion error",
+			`{"object":"error","status":401,"code":"unauthorized","message":"API token is invalid."}`,
+			[]string{`"code":"unauthorized"`, `"message":"API token is invalid."`},
+		},
+		{
+			"GraphQL error",
+			`{"errors":[{"message":"Authentication required","extensions":{"code":"AUTHENTICATION_ERROR"}}]}`,
+			[]string{`"code":"AUTHENTICATION_ERROR"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := redactBody("application/json", []byte(tt.body))
+			if strings.Contains(got, redacted) {
+				t.Errorf("redacted a field of %s", got)
+			}
+			for _, want := range tt.keep {
+				if !strings.Contains(got, want) {
+					t.Errorf("%s lost %s", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRedactJSONTokens(t *testing.T) {
+	body := `{"ok":true,"access_token":"xoxp-1","authed_user":{"access_token":"xoxp-2"},"incoming_webhook":{"url":"https://hooks.slack.com/services/T/B/X","channel":"#general"}}`
+
+	got := redactBody("application/json", []byte(body))
+	for _, secret := range []string{"xoxp-1", "xoxp-2", "hooks.slack.com"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("%s still contains %s", got, secret)
+		}
+	}
+	if !strings.Contains(got, `"channel":"#general"`) {
+		t.Errorf("%s lost the webhook channel", got)
+	}
+}
+
+func TestRedactURL(t *testing.T) {
+	u, err := url.Parse("http://localhost:8080/callback?code=the-code&state=abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := url.Parse(redactURL(u))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := got.Query().Get("code"); v != redacted {
+		t.Errorf("code = %q, want %q", v, redacted)
+	}
+	if v := got.Query().Get("state"); v != "abc" {
+		t.Errorf("state = %q, want abc", v)
+	}
+}
@@ -0,0 +1,206 @@
+package har
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"time"
+)
+
+// Transport is an http.RoundTripper that records every exchange
+type Transport struct {
+	Recorder *Recorder
+	Base     http.RoundTripper
+}
+
+// Wrap returns a transport that records traffic sent through base
+func (r *Recorder) Wrap(base http.RoundTripper) http.RoundTripper {
+	return &Transport{Recorder: r, Base: base}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		reqBody, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	trace := &phaseTrace{}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace.clientTrace()))
+
+	start := time.Now()
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		entry := t.entry(req, reqBody, start)
+		entry.Time = msSince(start)
+		entry.Comment = "request failed: " + err.Error()
+		t.Recorder.add(entry)
+		return nil, err
+	}
+
+	respBody, readErr := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	end := time.Now()
+
+	entry := t.entry(req, reqBody, start)
+	entry.Time = ms(end.Sub(start))
+	entry.Timings = trace.timings(start, end)
+	entry.Response = Response{
+		Status:      resp.StatusCode,
+		StatusText:  http.StatusText(resp.StatusCode),
+		HTTPVersion: resp.Proto,
+		Cookies:     []NameValue{},
+		Headers:     headerPairs(resp.Header),
+		Content: Content{
+			Size:     len(respBody),
+			MimeType: resp.Header.Get("Content-Type"),
+			Text:     redactBody(resp.Header.Get("Content-Type"), respBody),
+		},
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    len(respBody),
+	}
+	if readErr != nil {
+		entry.Comment = "failed to read response body: " + readErr.Error()
+	}
+	t.Recorder.add(entry)
+
+	if readErr != nil {
+		return nil, readErr
+	}
+	return resp, nil
+}
+
+// entry builds the request half of an entry
+func (t *Transport) entry(req *http.Request, body []byte, start time.Time) Entry {
+	query := []NameValue{}
+	values := req.URL.Query()
+	for _, name := range sortedKeys(values) {
+		for _, v := range values[name] {
+			query = append(query, NameValue{Name: name, Value: redactValue(name, v)})
+		}
+	}
+
+	entry := Entry{
+		StartedDateTime: start,
+		Request: Request{
+			Method:      req.Method,
+			URL:         redactURL(req.URL),
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []NameValue{},
+			Headers:     headerPairs(req.Header),
+			QueryString: query,
+			HeadersSize: -1,
+			BodySize:    len(body),
+		},
+		Timings: Timings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1},
+	}
+
+	if len(body) > 0 {
+		mimeType := req.Header.Get("Content-Type")
+		entry.Request.PostData = &PostData{
+			MimeType: mimeType,
+			Text:     redactBody(mimeType, body),
+		}
+	}
+
+	return entry
+}
+
+// phaseTrace records when each phase of a request happened
+type phaseTrace struct {
+	dnsStart, dnsDone         time.Time
+	connectStart, connectDone time.Time
+	tlsStart, tlsDone         time.Time
+	wroteRequest, firstByte   time.Time
+}
+
+func (p *phaseTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { p.dnsStart = time.Now() },
+		DNSDone:              func(httptrace.DNSDoneInfo) { p.dnsDone = time.Now() },
+		ConnectStart:         func(string, string) { p.connectStart = time.Now() },
+		ConnectDone:          func(string, string, error) { p.connectDone = time.Now() },
+		TLSHandshakeStart:    func() { p.tlsStart = time.Now() },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { p.tlsDone = time.Now() },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { p.wroteRequest = time.Now() },
+		GotFirstResponseByte: func() { p.firstByte = time.Now() },
+	}
+}
+
+func (p *phaseTrace) timings(start, end time.Time) Timings {
+	t := Timings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1}
+	if !p.dnsStart.IsZero() && !p.dnsDone.IsZero() {
+		t.DNS = ms(p.dnsDone.Sub(p.dnsStart))
+	}
+	if !p.connectStart.IsZero() && !p.connectDone.IsZero() {
+		t.Connect = ms(p.connectDone.Sub(p.connectStart))
+	}
+	if !p.tlsStart.IsZero() && !p.tlsDone.IsZero() {
+		t.SSL = ms(p.tlsDone.Sub(p.tlsStart))
+		// HAR counts the TLS handshake as part of connect
+		if t.Connect >= 0 {
+			t.Connect += t.SSL
+		}
+	}
+
+	// Replayed or otherwise synthetic responses have no trace events
+	if p.wroteRequest.IsZero() || p.firstByte.IsZero() {
+		t.Wait = ms(end.Sub(start))
+		return t
+	}
+
+	sendStart := start
+	for _, ts := range []time.Time{p.dnsDone, p.connectDone, p.tlsDone} {
+		if ts.After(sendStart) {
+			sendStart = ts
+		}
+	}
+	t.Send = ms(p.wroteRequest.Sub(sendStart))
+	t.Wait = ms(p.firstByte.Sub(p.wroteRequest))
+	t.Receive = ms(end.Sub(p.firstByte))
+	return t
+}
+
+func headerPairs(h http.Header) []NameValue {
+	pairs := []NameValue{}
+	for _, name := range sortedKeys(h) {
+		for _, v := range h[name] {
+			pairs = append(pairs, NameValue{Name: name, Value: redactHeader(name, v)})
+		}
+	}
+	return pairs
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func msSince(t time.Time) float64 {
+	return ms(time.Since(t))
+}
@@ -18,8 +18,8 @@ type ServerConfig struct {
 	Env     map[string]string `json:"env,omitempty"`
 }
 
-// cursorConfigPath returns the path to the Cursor MCP config file
-func cursorConfigPath() (string, error) {
+// CursorConfigPath returns the path to the Cursor MCP config file (~/.cursor/mcp.json)
+func CursorConfigPath() (string, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", err
@@ -29,7 +29,7 @@ func cursorConfigPath() (string, error) {
 
 // LoadCursorConfig loads the Cursor MCP configuration
 func LoadCursorConfig() (*CursorConfig, error) {
-	path, err := cursorConfigPath()
+	path, err := CursorConfigPath()
 	if err != nil {
 		return nil, err
 	}
@@ -58,7 +58,7 @@ func LoadCursorConfig() (*CursorConfig, error) {
 
 // SaveCursorConfig saves the Cursor MCP configuration
 func SaveCursorConfig(cfg *CursorConfig) error {
-	path, err := cursorConfigPath()
+	path, err := CursorConfigPath()
 	if err != nil {
 		return err
 	}
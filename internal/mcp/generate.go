@@ -8,8 +8,10 @@ import (
 	"github.com/jaknapp/applink/internal/storage"
 )
 
-// AddService adds or updates an MCP server configuration for a service
-func AddService(serviceName string) error {
+// AddService adds or updates an MCP server configuration for a service.
+// kind selects the user or bot token (see storage.Token.ForKind); if it is
+// empty, the service's MCPTokenKind is used when such a token is stored.
+func AddService(serviceName, kind string) error {
 	service, err := config.GetService(serviceName)
 	if err != nil {
 		return err
@@ -26,6 +28,14 @@ func AddService(serviceName string) error {
 	if token == nil {
 		return fmt.Errorf("no token found for %s", serviceName)
 	}
+	if kind == "" && service.MCPTokenKind != "" {
+		if preferred, err := token.ForKind(service.MCPTokenKind); err == nil {
+			token = preferred
+		}
+	}
+	if token, err = token.ForKind(kind); err != nil {
+		return err
+	}
 
 	// Build environment variables
 	env := make(map[string]string)
@@ -0,0 +1,616 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Filter is a compiled filter expression. It supports a subset of jq and
+// JSONPath that covers what scripts typically need:
+//
+//	.                  identity
+//	.foo .foo.bar      object fields ($.foo is accepted too)
+//	."a key" .["a key"]
+//	.[0] .[-1]         array elements
+//	.[] .[*] .*        all elements or values
+//	..  $..name        recursive descent
+//	a | b              pipes
+//	length, keys       builtins
+//	select(.a == "x")  filtering with ==, !=, <, <=, >, >=
+type Filter struct {
+	stages []stage
+}
+
+type stage interface {
+	apply(v interface{}) ([]interface{}, error)
+}
+
+// ParseFilter compiles a filter expression
+func ParseFilter(expr string) (*Filter, error) {
+	parts, err := splitTopLevel(expr, '|')
+	if err != nil {
+		return nil, err
+	}
+
+	f := &Filter{}
+	for _, part := range parts {
+		s, err := parseStage(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter %q: %w", expr, err)
+		}
+		f.stages = append(f.stages, s)
+	}
+	return f, nil
+}
+
+// Apply runs the filter and returns every value it produces
+func (f *Filter) Apply(v interface{}) ([]interface{}, error) {
+	values := []interface{}{v}
+	for _, s := range f.stages {
+		var next []interface{}
+		for _, value := range values {
+			out, err := s.apply(value)
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, out...)
+		}
+		values = next
+	}
+	return values, nil
+}
+
+// Iterates reports whether the filter can produce any number of values
+// (it uses [], .*, .. or select) rather than exactly one
+func (f *Filter) Iterates() bool {
+	for _, s := range f.stages {
+		switch st := s.(type) {
+		case *selectStage:
+			return true
+		case pathStage:
+			for _, step := range st {
+				if step.kind == stepIterate || step.kind == stepRecurse {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func parseStage(s string) (stage, error) {
+	switch {
+	case s == "":
+		return nil, fmt.Errorf("empty expression")
+	case s == "length":
+		return builtinStage(lengthOf), nil
+	case s == "keys":
+		return builtinStage(keysOf), nil
+	case strings.HasPrefix(s, "select(") && strings.HasSuffix(s, ")"):
+		return parseSelect(s[len("select(") : len(s)-1])
+	default:
+		return parsePath(s)
+	}
+}
+
+// path expressions
+
+type stepKind int
+
+const (
+	stepField stepKind = iota
+	stepIndex
+	stepIterate
+	stepRecurse
+)
+
+type step struct {
+	kind  stepKind
+	name  string
+	index int
+}
+
+type pathStage []step
+
+func parsePath(s string) (pathStage, error) {
+	if s[0] != '.' && s[0] != '$' {
+		return nil, fmt.Errorf("unsupported expression: %s", s)
+	}
+
+	var steps pathStage
+	i := 0
+	if s[0] == '$' {
+		i++
+	}
+
+	for i < len(s) {
+		switch s[i] {
+		case '.':
+			i++
+			if i < len(s) && s[i] == '.' {
+				steps = append(steps, step{kind: stepRecurse})
+				i++
+			}
+			if i >= len(s) || s[i] == '[' {
+				continue
+			}
+			switch {
+			case s[i] == '*':
+				steps = append(steps, step{kind: stepIterate})
+				i++
+			case s[i] == '"':
+				name, n, err := parseQuoted(s[i:])
+				if err != nil {
+					return nil, err
+				}
+				steps = append(steps, step{kind: stepField, name: name})
+				i += n
+			default:
+				start := i
+				for i < len(s) && isIdentChar(s[i]) {
+					i++
+				}
+				if start == i {
+					return nil, fmt.Errorf("unexpected %q at position %d", s[i], i)
+				}
+				steps = append(steps, step{kind: stepField, name: s[start:i]})
+			}
+		case '[':
+			end, err := matchingBracket(s, i)
+			if err != nil {
+				return nil, err
+			}
+			st, err := parseBracket(strings.TrimSpace(s[i+1 : end]))
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, st)
+			i = end + 1
+		default:
+			return nil, fmt.Errorf("unexpected %q at position %d", s[i], i)
+		}
+	}
+
+	return steps, nil
+}
+
+func parseBracket(content string) (step, error) {
+	if content == "" || content == "*" {
+		return step{kind: stepIterate}, nil
+	}
+	if content[0] == '"' || content[0] == '\'' {
+		name, n, err := parseQuoted(content)
+		if err != nil {
+			return step{}, err
+		}
+		if n != len(content) {
+			return step{}, fmt.Errorf("unexpected characters after %s", content[:n])
+		}
+		return step{kind: stepField, name: name}, nil
+	}
+	idx, err := strconv.Atoi(content)
+	if err != nil {
+		return step{}, fmt.Errorf("unsupported subscript [%s]", content)
+	}
+	return step{kind: stepIndex, index: idx}, nil
+}
+
+func (p pathStage) apply(v interface{}) ([]interface{}, error) {
+	values := []interface{}{v}
+	for i, st := range p {
+		// After recursive descent, like JSONPath, only keep values the
+		// next step actually matches instead of failing on the rest
+		afterRecurse := i > 0 && p[i-1].kind == stepRecurse
+
+		var next []interface{}
+		for _, value := range values {
+			if afterRecurse && !st.matches(value) {
+				continue
+			}
+			out, err := st.apply(value)
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, out...)
+		}
+		values = next
+	}
+	return values, nil
+}
+
+// matches reports whether a step selects anything from v
+func (st step) matches(v interface{}) bool {
+	switch st.kind {
+	case stepField:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		_, ok = m[st.name]
+		return ok
+	case stepIndex, stepIterate:
+		switch v.(type) {
+		case []interface{}:
+			return true
+		case map[string]interface{}:
+			return st.kind == stepIterate
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+func (st step) apply(v interface{}) ([]interface{}, error) {
+	switch st.kind {
+	case stepField:
+		switch val := v.(type) {
+		case nil:
+			return []interface{}{nil}, nil
+		case map[string]interface{}:
+			return []interface{}{val[st.name]}, nil
+		default:
+			return nil, fmt.Errorf("cannot index %s with %q", typeName(v), st.name)
+		}
+	case stepIndex:
+		switch val := v.(type) {
+		case nil:
+			return []interface{}{nil}, nil
+		case []interface{}:
+			idx := st.index
+			if idx < 0 {
+				idx += len(val)
+			}
+			if idx < 0 || idx >= len(val) {
+				return []interface{}{nil}, nil
+			}
+			return []interface{}{val[idx]}, nil
+		default:
+			return nil, fmt.Errorf("cannot index %s with number", typeName(v))
+		}
+	case stepIterate:
+		switch val := v.(type) {
+		case nil:
+			return nil, nil
+		case []interface{}:
+			return val, nil
+		case map[string]interface{}:
+			out := make([]interface{}, 0, len(val))
+			for _, k := range sortedKeys(val) {
+				out = append(out, val[k])
+			}
+			return out, nil
+		default:
+			return nil, fmt.Errorf("cannot iterate over %s", typeName(v))
+		}
+	case stepRecurse:
+		var out []interface{}
+		recurse(v, &out)
+		return out, nil
+	}
+	return nil, fmt.Errorf("unknown path step")
+}
+
+func recurse(v interface{}, out *[]interface{}) {
+	*out = append(*out, v)
+	switch val := v.(type) {
+	case []interface{}:
+		for _, item := range val {
+			recurse(item, out)
+		}
+	case map[string]interface{}:
+		for _, k := range sortedKeys(val) {
+			recurse(val[k], out)
+		}
+	}
+}
+
+// builtins
+
+type builtinStage func(v interface{}) (interface{}, error)
+
+func (b builtinStage) apply(v interface{}) ([]interface{}, error) {
+	out, err := b(v)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{out}, nil
+}
+
+func lengthOf(v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case nil:
+		return json.Number("0"), nil
+	case string:
+		return json.Number(strconv.Itoa(utf8.RuneCountInString(val))), nil
+	case []interface{}:
+		return json.Number(strconv.Itoa(len(val))), nil
+	case map[string]interface{}:
+		return json.Number(strconv.Itoa(len(val))), nil
+	default:
+		return nil, fmt.Errorf("%s has no length", typeName(v))
+	}
+}
+
+func keysOf(v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		keys := sortedKeys(val)
+		out := make([]interface{}, len(keys))
+		for i, k := range keys {
+			out[i] = k
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i := range val {
+			out[i] = json.Number(strconv.Itoa(i))
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("%s has no keys", typeName(v))
+	}
+}
+
+// select
+
+type selectStage struct {
+	path    pathStage
+	op      string
+	literal interface{}
+}
+
+var comparisonOps = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+func parseSelect(cond string) (stage, error) {
+	cond = strings.TrimSpace(cond)
+	for _, op := range comparisonOps {
+		idx := indexTopLevel(cond, op)
+		if idx < 0 {
+			continue
+		}
+		path, err := parsePath(strings.TrimSpace(cond[:idx]))
+		if err != nil {
+			return nil, err
+		}
+		literal, err := parseLiteral(strings.TrimSpace(cond[idx+len(op):]))
+		if err != nil {
+			return nil, err
+		}
+		return &selectStage{path: path, op: op, literal: literal}, nil
+	}
+
+	// No operator: select values where the path is truthy
+	path, err := parsePath(cond)
+	if err != nil {
+		return nil, err
+	}
+	return &selectStage{path: path}, nil
+}
+
+func (s *selectStage) apply(v interface{}) ([]interface{}, error) {
+	results, err := s.path.apply(v)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range results {
+		if s.matches(r) {
+			return []interface{}{v}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *selectStage) matches(v interface{}) bool {
+	switch s.op {
+	case "":
+		return v != nil && v != false
+	case "==":
+		return equal(v, s.literal)
+	case "!=":
+		return !equal(v, s.literal)
+	}
+
+	cmp, ok := compare(v, s.literal)
+	if !ok {
+		return false
+	}
+	switch s.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+func parseLiteral(s string) (interface{}, error) {
+	if strings.HasPrefix(s, "'") {
+		str, n, err := parseQuoted(s)
+		if err != nil {
+			return nil, err
+		}
+		if n != len(s) {
+			return nil, fmt.Errorf("unexpected characters after %s", s[:n])
+		}
+		return str, nil
+	}
+	v, err := Decode([]byte(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid literal %s", s)
+	}
+	return v, nil
+}
+
+func equal(a, b interface{}) bool {
+	if an, ok := toFloat(a); ok {
+		bn, ok := toFloat(b)
+		return ok && an == bn
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func compare(a, b interface{}) (int, bool) {
+	if an, ok := toFloat(a); ok {
+		bn, ok := toFloat(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case an < bn:
+			return -1, true
+		case an > bn:
+			return 1, true
+		default:
+			return 0, true
+		}
+	}
+	as, aok := a.(string)
+	bs, bok := b.(string)
+	if aok && bok {
+		return strings.Compare(as, bs), true
+	}
+	return 0, false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// lexing helpers
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '-' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// parseQuoted parses a single- or double-quoted string at the start of s
+// and returns its value and length
+func parseQuoted(s string) (string, int, error) {
+	quote := s[0]
+	var b bytes.Buffer
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			i++
+			b.WriteByte(s[i])
+		case c == quote:
+			return b.String(), i + 1, nil
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string: %s", s)
+}
+
+func matchingBracket(s string, open int) (int, error) {
+	for i := open + 1; i < len(s); i++ {
+		switch s[i] {
+		case '"', '\'':
+			_, n, err := parseQuoted(s[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n - 1
+		case ']':
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("missing ] in %s", s)
+}
+
+// splitTopLevel splits s on sep, ignoring separators inside quotes,
+// brackets and parentheses
+func splitTopLevel(s string, sep byte) ([]string, error) {
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"', '\'':
+			_, n, err := parseQuoted(s[i:])
+			if err != nil {
+				return nil, err
+			}
+			i += n - 1
+		case '[', '(':
+			depth++
+		case ']', ')':
+			depth--
+		case sep:
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:]), nil
+}
+
+// indexTopLevel finds op in s outside of quotes and brackets
+func indexTopLevel(s, op string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"', '\'':
+			_, n, err := parseQuoted(s[i:])
+			if err != nil {
+				return -1
+			}
+			i += n - 1
+			continue
+		case '[', '(':
+			depth++
+		case ']', ')':
+			depth--
+		}
+		if depth == 0 && strings.HasPrefix(s[i:], op) {
+			return i
+		}
+	}
+	return -1
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number, float64:
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
@@ -0,0 +1,196 @@
+package output
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const filterInput = `{
+	"ok": true,
+	"channels": [
+		{"id": "C1", "name": "general", "members": 10, "is_private": false},
+		{"id": "C2", "name": "random", "members": 3, "is_private": false},
+		{"id": "C3", "name": "secret", "members": 2, "is_private": true}
+	],
+	"meta": {"next": "abc", "a key": "spaced"},
+	"empty": []
+}`
+
+func TestFilterApply(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want string // JSON array of the results
+	}{
+		{"identity", ".", `[` + filterInput + `]`},
+		{"field", ".ok", `[true]`},
+		{"nested field", ".meta.next", `["abc"]`},
+		{"dollar root", "$.meta.next", `["abc"]`},
+		{"missing field", ".nope", `[null]`},
+		{"field of null", ".nope.deeper", `[null]`},
+		{"quoted field", `.meta."a key"`, `["spaced"]`},
+		{"bracket field", `.meta["a key"]`, `["spaced"]`},
+		{"single-quoted bracket field", `.meta['a key']`, `["spaced"]`},
+		{"index", ".channels[0].id", `["C1"]`},
+		{"negative index", ".channels[-1].id", `["C3"]`},
+		{"index out of range", ".channels[9]", `[null]`},
+		{"iterate array", ".channels[].id", `["C1","C2","C3"]`},
+		{"iterate with star", ".channels[*].id", `["C1","C2","C3"]`},
+		{"iterate object values", ".meta.*", `["spaced","abc"]`},
+		{"iterate empty array", ".empty[]", `[]`},
+		{"recursive descent", "$..next", `["abc"]`},
+		{"recursive descent field", "..id", `["C1","C2","C3"]`},
+		{"pipe", ".channels[] | .name", `["general","random","secret"]`},
+		{"pipe into index", ".channels | .[1] | .name", `["random"]`},
+		{"length of array", ".channels | length", `[3]`},
+		{"length of string", ".meta.next | length", `[3]`},
+		{"length of null", ".nope | length", `[0]`},
+		{"keys", ".meta | keys", `[["a key","next"]]`},
+		{"select equal", `.channels[] | select(.name == "random") | .id`, `["C2"]`},
+		{"select single quotes", `.channels[] | select(.name == 'random') | .id`, `["C2"]`},
+		{"select not equal", `.channels[] | select(.is_private != false) | .id`, `["C3"]`},
+		{"select greater", `.channels[] | select(.members > 2) | .id`, `["C1","C2"]`},
+		{"select less or equal", `.channels[] | select(.members <= 3) | .id`, `["C2","C3"]`},
+		{"select truthy", `.channels[] | select(.is_private) | .id`, `["C3"]`},
+		{"select no match", `.channels[] | select(.name == "none")`, `[]`},
+		{"pipe inside quotes", `.meta["a|b"]`, `[null]`},
+	}
+
+	input, err := Decode([]byte(filterInput))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseFilter(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseFilter(%q): %v", tt.expr, err)
+			}
+			results, err := f.Apply(input)
+			if err != nil {
+				t.Fatalf("Apply(%q): %v", tt.expr, err)
+			}
+			if results == nil {
+				results = []interface{}{}
+			}
+			assertJSON(t, results, tt.want)
+		})
+	}
+}
+
+func TestFilterParseErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"", "empty expression"},
+		{".a |", "empty expression"},
+		{"foo", "unsupported expression"},
+		{".a[", "missing ]"},
+		{".a[x]", "unsupported subscript"},
+		{`.a["x"y]`, "unexpected characters"},
+		{`."open`, "unterminated string"},
+		{".a!", "unexpected"},
+		{`select(.a == nope)`, "invalid literal"},
+		{`select(bad)`, "unsupported expression"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := ParseFilter(tt.expr)
+			if err == nil {
+				t.Fatalf("ParseFilter(%q) succeeded, want error", tt.expr)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseFilter(%q) error = %q, want it to contain %q", tt.expr, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterApplyErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{".ok.name", "cannot index boolean"},
+		{".channels.name", "cannot index array"},
+		{".meta[0]", "cannot index object with number"},
+		{".ok[]", "cannot iterate over boolean"},
+		{".ok | length", "boolean has no length"},
+		{".meta.next | keys", "string has no keys"},
+	}
+
+	input, err := Decode([]byte(filterInput))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			f, err := ParseFilter(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseFilter(%q): %v", tt.expr, err)
+			}
+			_, err = f.Apply(input)
+			if err == nil {
+				t.Fatalf("Apply(%q) succeeded, want error", tt.expr)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Apply(%q) error = %q, want it to contain %q", tt.expr, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterIterates(t *testing.T) {
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{".", false},
+		{".a.b", false},
+		{".a[0]", false},
+		{".a | length", false},
+		{".a | keys", false},
+		{".a[]", true},
+		{".a[*]", true},
+		{".a.*", true},
+		{"$..id", true},
+		{".a | .[] | .b", true},
+		{`select(.a == 1)`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			f, err := ParseFilter(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseFilter(%q): %v", tt.expr, err)
+			}
+			if got := f.Iterates(); got != tt.want {
+				t.Errorf("Iterates(%q) = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func assertJSON(t *testing.T, got interface{}, want string) {
+	t.Helper()
+	gotJSON, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantValue, err := Decode([]byte(want))
+	if err != nil {
+		t.Fatalf("bad expected JSON %s: %v", want, err)
+	}
+	wantJSON, err := json.Marshal(wantValue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(gotJSON) != string(wantJSON) {
+		t.Errorf("got %s, want %s", gotJSON, wantJSON)
+	}
+}
@@ -0,0 +1,205 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Format is an output format for structured data
+type Format string
+
+const (
+	FormatJSON  Format = "json"
+	FormatYAML  Format = "yaml"
+	FormatTable Format = "table"
+	FormatRaw   Format = "raw"
+)
+
+// ParseFormat validates a format name from the command line
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(strings.ToLower(name)); f {
+	case FormatJSON, FormatYAML, FormatTable, FormatRaw:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown output format: %s (expected json, yaml, table or raw)", name)
+	}
+}
+
+// Write writes a decoded JSON value (maps, slices and scalars as produced
+// by encoding/json) to w in the given format
+func Write(w io.Writer, v interface{}, format Format) error {
+	switch format {
+	case FormatJSON:
+		return writeJSON(w, v)
+	case FormatYAML:
+		return writeYAML(w, v)
+	case FormatTable:
+		return writeTable(w, v)
+	case FormatRaw:
+		return writeRaw(w, v)
+	default:
+		return fmt.Errorf("unknown output format: %s", format)
+	}
+}
+
+// Decode parses a JSON document, keeping numbers exact
+func Decode(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(v)
+}
+
+func writeYAML(w io.Writer, v interface{}) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(yamlValue(v)); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// yamlValue converts json.Number into int64/float64 so yaml.v3 emits
+// numbers rather than quoted strings
+func yamlValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return i
+		}
+		if f, err := val.Float64(); err == nil {
+			return f
+		}
+		return val.String()
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			out[k] = yamlValue(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = yamlValue(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// writeRaw prints strings without quotes and everything else as compact JSON
+func writeRaw(w io.Writer, v interface{}) error {
+	if items, ok := v.([]interface{}); ok {
+		for _, item := range items {
+			if _, err := fmt.Fprintln(w, cellString(item)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	_, err := fmt.Fprintln(w, cellString(v))
+	return err
+}
+
+// writeTable renders arrays of objects as rows with one column per key,
+// objects as KEY/VALUE rows, and anything else as a single column
+func writeTable(w io.Writer, v interface{}) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	switch val := v.(type) {
+	case []interface{}:
+		columns := tableColumns(val)
+		if len(columns) == 0 {
+			fmt.Fprintln(tw, "VALUE")
+			for _, item := range val {
+				fmt.Fprintln(tw, cellString(item))
+			}
+			break
+		}
+
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(columns, "\t")))
+		for _, item := range val {
+			row, _ := item.(map[string]interface{})
+			cells := make([]string, len(columns))
+			for i, col := range columns {
+				cells[i] = cellString(row[col])
+			}
+			fmt.Fprintln(tw, strings.Join(cells, "\t"))
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		fmt.Fprintln(tw, "KEY\tVALUE")
+		for _, k := range keys {
+			fmt.Fprintf(tw, "%s\t%s\n", k, cellString(val[k]))
+		}
+	default:
+		fmt.Fprintln(tw, cellString(val))
+	}
+
+	return tw.Flush()
+}
+
+// tableColumns returns the sorted union of keys of the objects in items,
+// or nil if items contains no objects
+func tableColumns(items []interface{}) []string {
+	seen := make(map[string]bool)
+	var columns []string
+	for _, item := range items {
+		row, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for k := range row {
+			if !seen[k] {
+				seen[k] = true
+				columns = append(columns, k)
+			}
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// cellString formats a value for table cells and raw output
+func cellString(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	case bool:
+		return fmt.Sprint(val)
+	default:
+		data, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprint(val)
+		}
+		return string(data)
+	}
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -33,7 +34,7 @@ func GetCredentials(service string) (*Credentials, error) {
 	}
 
 	// 2. Try keychain
-	creds, err := getCredentialsFromKeychain(service)
+	creds, err := GetStoredCredentials(service)
 	if err != nil {
 		return nil, err
 	}
@@ -53,16 +54,52 @@ func StoreCredentials(service string, creds *Credentials) error {
 		return fmt.Errorf("failed to store credentials in keychain: %w\n\n%s", err, keychainHelpMessage(service))
 	}
 
+	recordEntry(EntryCredentials, service)
 	return nil
 }
 
 // DeleteCredentials removes client credentials from the keychain
 func DeleteCredentials(service string) error {
 	err := keyring.Delete(credentialsPrefix, service)
-	if err == keyring.ErrNotFound {
-		return nil
+	if err != nil && err != keyring.ErrNotFound {
+		return err
+	}
+	forgetEntry(EntryCredentials, service)
+	return nil
+}
+
+// Credential sources reported by CredentialsSource and LookupToken
+const (
+	SourceEnv      = "env"
+	SourceKeychain = "keychain"
+)
+
+// CredentialsSource reports where GetCredentials would find credentials
+// for a service: SourceEnv, SourceKeychain, or "" if there are none
+func CredentialsSource(service string) (string, error) {
+	envPrefix := fmt.Sprintf("APPLINK_%s_", strings.ToUpper(service))
+	if os.Getenv(envPrefix+"CLIENT_ID") != "" && os.Getenv(envPrefix+"CLIENT_SECRET") != "" {
+		return SourceEnv, nil
 	}
-	return err
+
+	creds, err := GetStoredCredentials(service)
+	if err != nil {
+		return "", err
+	}
+	if creds != nil {
+		return SourceKeychain, nil
+	}
+	return "", nil
+}
+
+// CheckKeychain reports whether the system keychain can be reached. It
+// returns a *KeychainError if not.
+func CheckKeychain() error {
+	_, err := keyring.Get(serviceName, "applink-keychain-check")
+	if err != nil && err != keyring.ErrNotFound {
+		return &KeychainError{Err: err}
+	}
+	return nil
 }
 
 // HasCredentials checks if credentials exist (env vars or keychain)
@@ -71,8 +108,9 @@ func HasCredentials(service string) bool {
 	return creds != nil
 }
 
-// getCredentialsFromKeychain retrieves credentials from the system keychain
-func getCredentialsFromKeychain(service string) (*Credentials, error) {
+// GetStoredCredentials retrieves credentials from the system keychain,
+// ignoring environment variables
+func GetStoredCredentials(service string) (*Credentials, error) {
 	data, err := keyring.Get(credentialsPrefix, service)
 	if err != nil {
 		if err == keyring.ErrNotFound {
@@ -106,8 +144,8 @@ func (e *KeychainError) Unwrap() error {
 
 // IsKeychainError checks if an error is a keychain availability error
 func IsKeychainError(err error) bool {
-	_, ok := err.(*KeychainError)
-	return ok
+	var keychainErr *KeychainError
+	return errors.As(err, &keychainErr)
 }
 
 func keychainHelpMessage(service string) string {
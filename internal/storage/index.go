@@ -0,0 +1,204 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/jaknapp/applink/internal/config"
+	"github.com/zalando/go-keyring"
+)
+
+// The keychain can't be listed, so applink records what it stores in
+// ~/.applink/index.json. The index holds names only, never secrets.
+const indexFile = "index.json"
+
+// Kinds of keychain entries
+const (
+	EntryToken       = "token"       // Service token (StoreToken)
+	EntryCredentials = "credentials" // OAuth client credentials (StoreCredentials)
+	EntrySecret      = "secret"      // Internal secret, e.g. the CA key (StoreSecret)
+)
+
+// IndexEntry names one keychain entry stored by applink
+type IndexEntry struct {
+	Kind      string    `json:"kind"`
+	Name      string    `json:"name"` // Service ID, or the secret's name
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type secretIndex struct {
+	Entries []IndexEntry `json:"entries"`
+}
+
+// keyringService returns the keychain service the entry is stored under
+func (e IndexEntry) keyringService() string {
+	switch e.Kind {
+	case EntryCredentials:
+		return credentialsPrefix
+	case EntrySecret:
+		return secretsService
+	default:
+		return serviceName
+	}
+}
+
+func indexPath() (string, error) {
+	dir, err := config.GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, indexFile), nil
+}
+
+// IndexedEntries returns the entries in the index, sorted by kind and name
+func IndexedEntries() ([]IndexEntry, error) {
+	idx, err := loadIndex()
+	if err != nil {
+		return nil, err
+	}
+	return idx.Entries, nil
+}
+
+// EntryExists checks the keychain for an entry
+func EntryExists(kind, name string) (bool, error) {
+	_, err := keyring.Get(IndexEntry{Kind: kind}.keyringService(), name)
+	switch {
+	case err == keyring.ErrNotFound:
+		return false, nil
+	case err != nil:
+		return false, &KeychainError{Err: err, Service: name}
+	}
+	return true, nil
+}
+
+// DeleteEntry removes an entry from the keychain and the index
+func DeleteEntry(kind, name string) error {
+	err := keyring.Delete(IndexEntry{Kind: kind}.keyringService(), name)
+	if err != nil && err != keyring.ErrNotFound {
+		return err
+	}
+	forgetEntry(kind, name)
+	return nil
+}
+
+// IndexEntries adds entries that exist in the keychain but not in the
+// index, e.g. ones stored by older versions of applink
+func IndexEntries(entries []IndexEntry) error {
+	idx, err := loadIndex()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		idx.add(e.Kind, e.Name, e.UpdatedAt)
+	}
+	return idx.save()
+}
+
+// ForgetEntry removes an entry from the index without touching the
+// keychain, for entries that no longer exist there
+func ForgetEntry(kind, name string) {
+	forgetEntry(kind, name)
+}
+
+// recordEntry notes a stored entry in the index. The index is only a
+// listing aid, so failing to update it doesn't fail the store.
+func recordEntry(kind, name string) {
+	idx, err := loadIndex()
+	if err != nil {
+		return
+	}
+	idx.add(kind, name, time.Now().UTC())
+	idx.save()
+}
+
+// forgetEntry removes an entry from the index
+func forgetEntry(kind, name string) {
+	idx, err := loadIndex()
+	if err != nil {
+		return
+	}
+	for i, e := range idx.Entries {
+		if e.Kind == kind && e.Name == name {
+			idx.Entries = append(idx.Entries[:i], idx.Entries[i+1:]...)
+			idx.save()
+			return
+		}
+	}
+}
+
+func (idx *secretIndex) add(kind, name string, updatedAt time.Time) {
+	for i, e := range idx.Entries {
+		if e.Kind == kind && e.Name == name {
+			idx.Entries[i].UpdatedAt = updatedAt
+			return
+		}
+	}
+	idx.Entries = append(idx.Entries, IndexEntry{Kind: kind, Name: name, UpdatedAt: updatedAt})
+	sort.Slice(idx.Entries, func(i, j int) bool {
+		if idx.Entries[i].Kind != idx.Entries[j].Kind {
+			return idx.Entries[i].Kind < idx.Entries[j].Kind
+		}
+		return idx.Entries[i].Name < idx.Entries[j].Name
+	})
+}
+
+// loadIndex reads the index. A missing file yields an empty index.
+func loadIndex() (*secretIndex, error) {
+	path, err := indexPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &secretIndex{}, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var idx secretIndex
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &idx, nil
+}
+
+func (idx *secretIndex) save() error {
+	path, err := indexPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write through a temporary file so a crash can't leave a truncated
+	// index behind
+	tmp, err := os.CreateTemp(filepath.Dir(path), indexFile+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
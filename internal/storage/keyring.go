@@ -2,6 +2,10 @@ package storage
 
 import (
 	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/zalando/go-keyring"
@@ -15,11 +19,44 @@ type Token struct {
 	RefreshToken string    `json:"refresh_token,omitempty"`
 	TokenType    string    `json:"token_type,omitempty"`
 	ExpiresAt    time.Time `json:"expires_at,omitempty"`
-	Scope        string    `json:"scope,omitempty"`
+	Scope        string    `json:"scope,omitempty"` // Granted scopes, as reported by the provider
+
+	// RequestedScopes are the scopes asked for at login
+	RequestedScopes []string `json:"requested_scopes,omitempty"`
 
 	// Service-specific fields
-	TeamID string `json:"team_id,omitempty"` // Slack team ID
-	User   string `json:"user,omitempty"`    // User email/name
+	TeamID      string `json:"team_id,omitempty"`      // Slack team ID
+	User        string `json:"user,omitempty"`         // User email/name
+	UserID      string `json:"user_id,omitempty"`      // User ID at the service
+	Workspace   string `json:"workspace,omitempty"`    // Workspace/team name
+	WorkspaceID string `json:"workspace_id,omitempty"` // Workspace/team ID
+
+	// Slack: a login can return a bot token alongside the user token
+	Bot             *BotToken        `json:"bot,omitempty"`
+	EnterpriseID    string           `json:"enterprise_id,omitempty"`   // Slack Enterprise Grid org ID
+	EnterpriseName  string           `json:"enterprise_name,omitempty"` // Slack Enterprise Grid org name
+	IncomingWebhook *IncomingWebhook `json:"incoming_webhook,omitempty"`
+}
+
+// BotToken is a Slack bot token (xoxb-...) granted with bot scopes
+type BotToken struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at,omitempty"`
+	Scope        string    `json:"scope,omitempty"`
+	UserID       string    `json:"user_id,omitempty"` // The bot's user ID
+
+	// RequestedScopes are the bot scopes asked for at login
+	RequestedScopes []string `json:"requested_scopes,omitempty"`
+}
+
+// IncomingWebhook is a Slack incoming webhook created during login
+// (incoming-webhook scope)
+type IncomingWebhook struct {
+	URL              string `json:"url"`
+	Channel          string `json:"channel,omitempty"`
+	ChannelID        string `json:"channel_id,omitempty"`
+	ConfigurationURL string `json:"configuration_url,omitempty"`
 }
 
 // StoreToken saves a token to the system keychain
@@ -29,11 +66,39 @@ func StoreToken(service string, token *Token) error {
 		return err
 	}
 
-	return keyring.Set(serviceName, service, string(data))
+	if err := keyring.Set(serviceName, service, string(data)); err != nil {
+		return err
+	}
+	recordEntry(EntryToken, service)
+	return nil
 }
 
-// GetToken retrieves a token from the system keychain
+// GetToken retrieves a token for a service.
+// Priority: Environment variables → Keychain
 func GetToken(service string) (*Token, error) {
+	token, _, err := LookupToken(service)
+	return token, err
+}
+
+// LookupToken is GetToken, but also reports where the token came from:
+// SourceEnv or SourceKeychain ("" if there is no token)
+func LookupToken(service string) (*Token, string, error) {
+	// 1. Check environment variables first
+	if token, err := tokenFromEnv(service); token != nil || err != nil {
+		return token, SourceEnv, err
+	}
+
+	// 2. Try keychain
+	token, err := GetStoredToken(service)
+	if token == nil || err != nil {
+		return nil, "", err
+	}
+	return token, SourceKeychain, nil
+}
+
+// GetStoredToken retrieves a token from the system keychain, ignoring
+// environment variables
+func GetStoredToken(service string) (*Token, error) {
 	data, err := keyring.Get(serviceName, service)
 	if err != nil {
 		if err == keyring.ErrNotFound {
@@ -50,11 +115,53 @@ func GetToken(service string) (*Token, error) {
 	return &token, nil
 }
 
+// HasEnvToken reports whether APPLINK_<SERVICE>_TOKEN is set, which hides
+// the token in the keychain
+func HasEnvToken(service string) bool {
+	return os.Getenv(tokenEnvPrefix(service)+"TOKEN") != ""
+}
+
+func tokenEnvPrefix(service string) string {
+	return fmt.Sprintf("APPLINK_%s_", strings.ToUpper(service))
+}
+
+// tokenFromEnv reads a token from APPLINK_<SERVICE>_TOKEN and the optional
+// _REFRESH_TOKEN, _EXPIRES_AT (RFC 3339 or Unix seconds) and _TEAM_ID. It
+// returns nil if APPLINK_<SERVICE>_TOKEN is not set. Such tokens are
+// read-only: applink never writes them back.
+func tokenFromEnv(service string) (*Token, error) {
+	envPrefix := tokenEnvPrefix(service)
+	accessToken := os.Getenv(envPrefix + "TOKEN")
+	if accessToken == "" {
+		return nil, nil
+	}
+
+	token := &Token{
+		AccessToken:  accessToken,
+		RefreshToken: os.Getenv(envPrefix + "REFRESH_TOKEN"),
+		TeamID:       os.Getenv(envPrefix + "TEAM_ID"),
+	}
+	token.WorkspaceID = token.TeamID
+
+	if expiresAt := os.Getenv(envPrefix + "EXPIRES_AT"); expiresAt != "" {
+		if seconds, err := strconv.ParseInt(expiresAt, 10, 64); err == nil {
+			token.ExpiresAt = time.Unix(seconds, 0)
+		} else if t, err := time.Parse(time.RFC3339, expiresAt); err == nil {
+			token.ExpiresAt = t
+		} else {
+			return nil, fmt.Errorf("invalid %sEXPIRES_AT %q: expected RFC 3339 or Unix seconds", envPrefix, expiresAt)
+		}
+	}
+
+	return token, nil
+}
+
 // DeleteToken removes a token from the system keychain
 func DeleteToken(service string) error {
 	err := keyring.Delete(serviceName, service)
-	if err == keyring.ErrNotFound {
-		return nil // Already deleted
+	if err != nil && err != keyring.ErrNotFound {
+		return err
 	}
-	return err
+	forgetEntry(EntryToken, service)
+	return nil
 }
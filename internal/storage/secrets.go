@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"github.com/zalando/go-keyring"
+)
+
+const secretsService = "applink_secrets"
+
+// StoreSecret saves an internal secret (such as the CA key) to the
+// system keychain
+func StoreSecret(name, value string) error {
+	if err := keyring.Set(secretsService, name, value); err != nil {
+		return &KeychainError{Err: err}
+	}
+	recordEntry(EntrySecret, name)
+	return nil
+}
+
+// GetSecret retrieves an internal secret. It returns "" if the secret
+// doesn't exist.
+func GetSecret(name string) (string, error) {
+	value, err := keyring.Get(secretsService, name)
+	if err != nil {
+		if err == keyring.ErrNotFound {
+			return "", nil
+		}
+		return "", &KeychainError{Err: err}
+	}
+	return value, nil
+}
+
+// DeleteSecret removes an internal secret
+func DeleteSecret(name string) error {
+	err := keyring.Delete(secretsService, name)
+	if err != nil && err != keyring.ErrNotFound {
+		return err
+	}
+	forgetEntry(EntrySecret, name)
+	return nil
+}
@@ -1,6 +1,15 @@
 package storage
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
+
+// Token kinds for services that issue both user and bot tokens (Slack)
+const (
+	TokenKindUser = "user"
+	TokenKindBot  = "bot"
+)
 
 // IsExpired returns true if the token has expired
 func (t *Token) IsExpired() bool {
@@ -18,3 +27,37 @@ func (t *Token) NeedsRefresh() bool {
 	}
 	return time.Now().Add(5 * time.Minute).After(t.ExpiresAt)
 }
+
+// ForKind returns the token to use for the given kind. The bot kind is a
+// copy of the token with the bot's access token and scopes. An empty
+// kind prefers the user token and falls back to the bot token.
+func (t *Token) ForKind(kind string) (*Token, error) {
+	switch kind {
+	case "":
+		if t.AccessToken == "" && t.Bot != nil {
+			return t.ForKind(TokenKindBot)
+		}
+		return t, nil
+	case TokenKindUser:
+		if t.AccessToken == "" {
+			return nil, fmt.Errorf("no user token stored (only a bot token)")
+		}
+		return t, nil
+	case TokenKindBot:
+		if t.Bot == nil || t.Bot.AccessToken == "" {
+			return nil, fmt.Errorf("no bot token stored; log in again with --bot-scope")
+		}
+		bot := *t
+		bot.AccessToken = t.Bot.AccessToken
+		bot.RefreshToken = t.Bot.RefreshToken
+		bot.ExpiresAt = t.Bot.ExpiresAt
+		bot.Scope = t.Bot.Scope
+		bot.RequestedScopes = t.Bot.RequestedScopes
+		bot.TokenType = "bot"
+		bot.UserID = t.Bot.UserID
+		bot.User = ""
+		return &bot, nil
+	default:
+		return nil, fmt.Errorf("unknown token kind: %s (expected user or bot)", kind)
+	}
+}